@@ -4,117 +4,224 @@ import (
 	"bufio"
 	"errors"
 	"image"
+	"io"
 	"os"
 	"path"
+	"strconv"
 	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/wayneashleyberry/lut/pkg/colorcube"
 	"github.com/wayneashleyberry/lut/pkg/cubelut"
 	"github.com/wayneashleyberry/lut/pkg/imagelut"
+	"github.com/wayneashleyberry/lut/pkg/lut"
+	"github.com/wayneashleyberry/lut/pkg/prism"
+	"github.com/wayneashleyberry/lut/pkg/pyramid"
+	"github.com/wayneashleyberry/lut/pkg/stream"
+	"github.com/wayneashleyberry/lut/pkg/tetrahedral"
+	"github.com/wayneashleyberry/lut/pkg/tricubic"
 	"github.com/wayneashleyberry/lut/pkg/trilinear"
 	"github.com/wayneashleyberry/lut/pkg/util"
 )
 
 // Sentinel error values.
 var (
-	ErrInvalidInterpolation = errors.New("invalid interpolation, accepted values are `none`, `tri` and `interp`")
+	ErrInvalidInterpolation = errors.New("invalid interpolation, accepted values are `none`, `tri`, `tetra`, `prism`, `pyramid`, `cubic` and `bspline`")
+	ErrInvalidAlpha         = errors.New("invalid alpha mode, accepted values are `straight`, `premultiplied` and `skip`")
+	ErrInvalidDither        = errors.New("invalid dither, accepted values are `none`, `ordered`, `bluenoise` and `floyd-steinberg`")
+	ErrInvalidFixed         = errors.New("fixed point is only available for `tri` and `tetra` interpolation")
+	ErrInvalidMaskChannel   = errors.New("invalid mask channel, accepted values are `luma` and `alpha`")
+	ErrInvalidClip          = errors.New("invalid clip policy, accepted values are `hard`, `soft` and `preserve-hue`")
+	ErrInvalidRange         = errors.New("invalid qualifier range, expected `min:max`")
+	ErrInvalidBlend         = errors.New("invalid blend mode, accepted values are `normal`, `luminosity`, `color`, `overlay`, `soft-light`, `multiply` and `screen`")
 )
 
+var alphaModes = map[string]lut.AlphaMode{
+	"straight":      lut.Straight,
+	"premultiplied": lut.Premultiplied,
+	"skip":          lut.SkipTransparent,
+}
+
+var dithers = map[string]lut.Dither{
+	"none":            lut.NoDither,
+	"ordered":         lut.Ordered,
+	"bluenoise":       lut.BlueNoise,
+	"floyd-steinberg": lut.FloydSteinberg,
+}
+
+var blendModes = map[string]lut.BlendMode{
+	"normal":     lut.Normal,
+	"luminosity": lut.Luminosity,
+	"color":      lut.Color,
+	"overlay":    lut.Overlay,
+	"soft-light": lut.SoftLight,
+	"multiply":   lut.Multiply,
+	"screen":     lut.Screen,
+}
+
+var clips = map[string]lut.Clip{
+	"hard":         lut.HardClip,
+	"soft":         lut.SoftClip,
+	"preserve-hue": lut.PreserveHue,
+}
+
+var maskChannels = map[string]lut.MaskChannel{
+	"luma":  lut.MaskLuma,
+	"alpha": lut.MaskAlpha,
+}
+
+var interpolators = map[string]colorcube.Interpolator{
+	"none":    colorcube.Nearest,
+	"tri":     trilinear.Lookup,
+	"tetra":   tetrahedral.Lookup,
+	"prism":   prism.Lookup,
+	"pyramid": pyramid.Lookup,
+	"cubic":   tricubic.CatmullRom,
+	"bspline": tricubic.BSpline,
+}
+
+var fixedKernels = map[string]func(colorcube.Cube) lut.Fixed{
+	"tri": func(cube colorcube.Cube) lut.Fixed {
+		return trilinear.NewFixed(cube)
+	},
+	"tetra": func(cube colorcube.Cube) lut.Fixed {
+		return tetrahedral.NewFixed(cube)
+	},
+}
+
+var decoders = map[string]func(io.Reader) (stream.Decoder, error){
+	".png": stream.NewPNGDecoder,
+	".ppm": stream.NewPPMDecoder,
+	".pgm": stream.NewPPMDecoder,
+}
+
+var encoders = map[string]func(io.Writer, stream.Header) (stream.Encoder, error){
+	".png": stream.NewPNGEncoder,
+	".ppm": stream.NewPPMEncoder,
+}
+
 // Command will create a new "apply" command.
 func Command() *cobra.Command {
-	var lutfile, outfile string
+	var lutfile, outfile, maskfile string
 
 	var intensity float64
 
-	var interp string
+	var interp, alpha, dither, blend, clip, maskChannel string
+
+	var qualifyHue, qualifySat, qualifyLuma, policy string
+
+	var softness float64
+
+	var fixed, invertMask bool
+
+	var workers, memory int
+
+	var streamAbove float64
 
 	cmd := &cobra.Command{
 		Use:   "apply [source.png] --lut sepia.png --out image.png --interp none",
 		Short: "Adjust image colour according to a LUT",
 		Args:  cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			srcimg, err := util.ReadImage(args[0])
+			interpolate, ok := interpolators[interp]
+			if !ok {
+				util.Exit(ErrInvalidInterpolation)
+			}
+
+			mode, ok := alphaModes[alpha]
+			if !ok {
+				util.Exit(ErrInvalidAlpha)
+			}
+
+			d, ok := dithers[dither]
+			if !ok {
+				util.Exit(ErrInvalidDither)
+			}
+
+			newFixed, ok := fixedKernels[interp]
+			if fixed && !ok {
+				util.Exit(ErrInvalidFixed)
+			}
+
+			b, ok := blendModes[blend]
+			if !ok {
+				util.Exit(ErrInvalidBlend)
+			}
+
+			c, ok := clips[clip]
+			if !ok {
+				util.Exit(ErrInvalidClip)
+			}
+
+			channel, ok := maskChannels[maskChannel]
+			if !ok {
+				util.Exit(ErrInvalidMaskChannel)
+			}
+
+			q, err := qualifier(qualifyHue, qualifySat, qualifyLuma, softness)
 			if err != nil {
 				util.Exit(err)
 			}
 
-			var out image.Image
+			opts := []lut.Option{
+				lut.WithAlpha(mode),
+				lut.WithDither(d),
+				lut.WithBlend(b),
+				lut.WithClip(c),
+				lut.WithQualifier(q),
+				lut.WithWorkers(workers),
+			}
 
-			switch strings.ToLower(path.Ext(lutfile)) {
-			case ".cube":
-				file, err := os.Open(lutfile)
+			cube, err := readCube(lutfile)
+			if err != nil {
+				util.Exit(err)
+			}
+
+			if policy != "" {
+				cube.DomainPolicy, err = colorcube.ParseDomainPolicy(policy)
 				if err != nil {
 					util.Exit(err)
 				}
-				defer file.Close()
+			}
 
-				r := bufio.NewReader(file)
+			if fixed {
+				opts = append(opts, lut.WithFixed(newFixed(cube)))
+			}
 
-				cubefile, err := cubelut.Parse(r)
-				if err != nil {
-					util.Exit(err)
-				}
+			mask := lut.Mask{Channel: channel, Invert: invertMask}
 
-				switch interp {
-				case "tri":
-					cube := cubefile.Cube()
-
-					img, err := trilinear.Interpolate(srcimg, cube, intensity)
-					if err != nil {
-						util.Exit(err)
-					}
-
-					out = img
-				case "tetra":
-					panic("not implemented")
-				case "none":
-					img, err := cubefile.Apply(srcimg, intensity)
-					if err != nil {
-						util.Exit(err)
-					}
-
-					out = img
-				default:
-					util.Exit(ErrInvalidInterpolation)
-				}
-			case ".png":
-				fallthrough
-			case ".jpg":
-				fallthrough
-			case ".jpeg":
-				lutimg, err := util.ReadImage(lutfile)
+			if maskfile != "" {
+				mask.Image, err = util.ReadImage(maskfile)
 				if err != nil {
 					util.Exit(err)
 				}
+			}
 
-				switch interp {
-				case "tri":
-					cube, err := imagelut.Parse(lutimg)
-					if err != nil {
-						util.Exit(err)
-					}
-
-					img, err := trilinear.Interpolate(srcimg, cube, intensity)
-					if err != nil {
-						util.Exit(err)
-					}
-
-					out = img
-				case "tetra":
-					panic("not implemented")
-				case "none":
-					img, err := imagelut.Apply(srcimg, lutimg, intensity)
-					if err != nil {
-						util.Exit(err)
-					}
-
-					out = img
-				default:
-					util.Exit(ErrInvalidInterpolation)
-				}
+			fn := func(r, g, b float64) (float64, float64, float64) {
+				return interpolate(cube, r, g, b)
+			}
 
-			default:
-				util.Exit(errors.New("unsupported file type"))
+			streamed, err := applyStream(args[0], outfile, fn, intensity, streamAbove*1e6, memory<<20, mask, opts...)
+			if err != nil {
+				util.Exit(err)
+			}
+
+			if streamed {
+				return
+			}
+
+			srcimg, err := util.ReadImage(args[0])
+			if err != nil {
+				util.Exit(err)
+			}
+
+			if mask.Image != nil {
+				opts = append(opts, lut.WithMask(mask))
+			}
+
+			out, err := lut.Apply(srcimg, fn, intensity, opts...)
+			if err != nil {
+				util.Exit(err)
 			}
 
 			if err := util.WriteImage(outfile, out); err != nil {
@@ -123,8 +230,24 @@ func Command() *cobra.Command {
 		},
 	}
 
-	cmd.Flags().Float64VarP(&intensity, "intensity", "", 1, "Intensity of the applied effect")
-	cmd.Flags().StringVarP(&interp, "interp", "i", "tri", "Interpolation")
+	cmd.Flags().Float64VarP(&intensity, "intensity", "", 1, "Intensity of the applied effect, values above 1 exaggerate it and values below 0 reverse it")
+	cmd.Flags().StringVarP(&clip, "clip", "", "hard", "Handling of colours pushed out of range by the intensity (hard, soft or preserve-hue)")
+	cmd.Flags().StringVarP(&interp, "interp", "i", "tri", "Interpolation (none, tri, tetra, prism, pyramid, cubic or bspline)")
+	cmd.Flags().StringVarP(&alpha, "alpha", "", "straight", "Grading of transparent pixels (straight, premultiplied or skip)")
+	cmd.Flags().BoolVarP(&fixed, "fixed", "", false, "Use integer arithmetic for tri and tetra interpolation of 8 and 16 bit images")
+	cmd.Flags().IntVarP(&workers, "workers", "", 0, "Number of goroutines used for grading, 0 uses one per CPU")
+	cmd.Flags().Float64VarP(&streamAbove, "stream-above", "", 64, "Stream png and ppm images with more megapixels than this through the LUT in strips")
+	cmd.Flags().IntVarP(&memory, "memory", "", 256, "Memory budget in MiB for the pixels of streamed images")
+	cmd.Flags().StringVarP(&qualifyHue, "qualify-hue", "", "", "Only grade hues in this range of degrees, like 20:50 or 340:20")
+	cmd.Flags().StringVarP(&qualifySat, "qualify-sat", "", "", "Only grade saturations in this range, like 0.3:1")
+	cmd.Flags().StringVarP(&qualifyLuma, "qualify-luma", "", "", "Only grade lumas in this range, like 0.5:1")
+	cmd.Flags().Float64VarP(&softness, "softness", "", 0.1, "Softness of the qualifier ranges, as a fraction of the full hue, saturation or luma range")
+	cmd.Flags().StringVarP(&policy, "domain-policy", "", "", "Handling of colours outside of the LUT domain (clamp, extrapolate or soft-clip), defaults to the policy of the LUT")
+	cmd.Flags().StringVarP(&blend, "blend", "", "normal", "Blending of graded colours (normal, luminosity, color, overlay, soft-light, multiply or screen)")
+	cmd.Flags().StringVarP(&maskfile, "mask", "", "", "Path to an image which sets the intensity of every pixel, resampled to the size of the source")
+	cmd.Flags().StringVarP(&maskChannel, "mask-channel", "", "luma", "Channel of the mask which sets the intensity (luma or alpha)")
+	cmd.Flags().BoolVarP(&invertMask, "invert-mask", "", false, "Grade the dark or transparent parts of the mask instead")
+	cmd.Flags().StringVarP(&dither, "dither", "", "none", "Dithering of 8 bit output (none, ordered, bluenoise or floyd-steinberg)")
 
 	// Required flags
 	cmd.Flags().StringVarP(&lutfile, "lut", "", "", "Path to LUT [required]")
@@ -135,3 +258,133 @@ func Command() *cobra.Command {
 
 	return cmd
 }
+
+// qualifier will create a qualifier from the min:max ranges of the command
+// line flags, empty ranges select every value. Softness is a fraction of the
+// full range, so it is scaled to degrees for hues.
+func qualifier(hue, sat, luma string, softness float64) (lut.Qualifier, error) {
+	var q lut.Qualifier
+
+	ranges := []struct {
+		s     string
+		r     *lut.Range
+		scale float64
+	}{
+		{hue, &q.Hue, 360},
+		{sat, &q.Saturation, 1},
+		{luma, &q.Luma, 1},
+	}
+
+	for _, r := range ranges {
+		if r.s == "" {
+			continue
+		}
+
+		parts := strings.Split(r.s, ":")
+		if len(parts) != 2 {
+			return q, ErrInvalidRange
+		}
+
+		min, err := strconv.ParseFloat(parts[0], 64)
+		if err != nil {
+			return q, ErrInvalidRange
+		}
+
+		max, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return q, ErrInvalidRange
+		}
+
+		*r.r = lut.Range{Min: min, Max: max, Softness: softness * r.scale}
+	}
+
+	return q, nil
+}
+
+// readCube will read a LUT stored in a .cube file or an image.
+func readCube(filename string) (colorcube.Cube, error) {
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".cube":
+		file, err := os.Open(filename)
+		if err != nil {
+			return colorcube.Cube{}, err
+		}
+		defer file.Close()
+
+		cubefile, err := cubelut.Parse(bufio.NewReader(file))
+		if err != nil {
+			return colorcube.Cube{}, err
+		}
+
+		return cubefile.Cube(), nil
+	case ".png", ".jpg", ".jpeg":
+		lutimg, err := util.ReadImage(filename)
+		if err != nil {
+			return colorcube.Cube{}, err
+		}
+
+		return imagelut.Parse(lutimg)
+	default:
+		return colorcube.Cube{}, errors.New("unsupported file type")
+	}
+}
+
+// applyStream will grade src into dst a strip at a time when both are png or
+// ppm images and src has more than threshold pixels. It reports whether the
+// image was streamed, images which can't be streamed are left untouched. A
+// mask with an image is stretched over the whole of src, not each strip.
+func applyStream(src, dst string, fn lut.Func, intensity, threshold float64, budget int, mask lut.Mask, opts ...lut.Option) (bool, error) {
+	newDecoder, ok := decoders[strings.ToLower(path.Ext(src))]
+	if !ok {
+		return false, nil
+	}
+
+	newEncoder, ok := encoders[strings.ToLower(path.Ext(dst))]
+	if !ok {
+		return false, nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return false, err
+	}
+	defer in.Close()
+
+	dec, err := newDecoder(in)
+	if err == stream.ErrInterlaced {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	h := dec.Header()
+	if float64(h.Width)*float64(h.Height) <= threshold {
+		return false, nil
+	}
+
+	if mask.Image != nil {
+		mask.Bounds = image.Rect(0, 0, h.Width, h.Height)
+		opts = append(opts, lut.WithMask(mask))
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return false, err
+	}
+	defer out.Close()
+
+	w := bufio.NewWriter(out)
+
+	enc, err := newEncoder(w, h)
+	if err != nil {
+		return false, err
+	}
+
+	if err := stream.Apply(dec, enc, fn, intensity, budget, opts...); err != nil {
+		return false, err
+	}
+
+	return true, w.Flush()
+}
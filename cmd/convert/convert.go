@@ -16,8 +16,14 @@ import (
 	"github.com/wayneashleyberry/lut/pkg/util"
 )
 
+// ErrPolicyInImage is returned when a domain policy other than clamp would be
+// lost by converting to an image LUT.
+var ErrPolicyInImage = errors.New("image LUTs can't record a domain policy, use `--domain-policy clamp` to drop it")
+
 // Command will create a new convert command.
 func Command() *cobra.Command {
+	var policy string
+
 	cmd := &cobra.Command{
 		Use:   "convert [source.png] target.cube",
 		Short: "Convert a LUT file to a different format",
@@ -60,6 +66,15 @@ func Command() *cobra.Command {
 				util.Exit(errors.New("unsupported file type: " + in))
 			}
 
+			if policy != "" {
+				p, err := colorcube.ParseDomainPolicy(policy)
+				if err != nil {
+					util.Exit(err)
+				}
+
+				cube.DomainPolicy = p
+			}
+
 			switch strings.ToLower(path.Ext(out)) {
 			case ".cube":
 				f := cubelut.FromColorCube(cube)
@@ -75,6 +90,10 @@ func Command() *cobra.Command {
 					util.Exit(err)
 				}
 			case ".png":
+				if cube.DomainPolicy != colorcube.Clamp {
+					util.Exit(ErrPolicyInImage)
+				}
+
 				img := imagelut.FromColorCube(cube)
 
 				err := util.WriteImage(out, img)
@@ -87,5 +106,7 @@ func Command() *cobra.Command {
 		},
 	}
 
+	cmd.Flags().StringVarP(&policy, "domain-policy", "", "", "Handling of colours outside of the LUT domain (clamp, extrapolate or soft-clip), recorded in .cube files, defaults to the policy of the source")
+
 	return cmd
 }
@@ -0,0 +1,273 @@
+// Package baked implements a precomputed lookup table which speeds up applying
+// the same colour cube to many images. The cube is evaluated once with any
+// interpolator at every point of a regular RGB grid, after which looking up a
+// colour only needs a cheap trilinear blend of the baked values.
+//
+// With 8 bits per channel the grid has a point for every 8 bit colour, so
+// 8 bit images never fall between points and the interpolator is reproduced
+// exactly, each pixel is a single read from the table. Fewer bits make the
+// table much smaller at the cost of some accuracy.
+package baked
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"io"
+	"math"
+
+	"github.com/wayneashleyberry/lut/pkg/colorcube"
+	"github.com/wayneashleyberry/lut/pkg/fixed"
+	"github.com/wayneashleyberry/lut/pkg/lut"
+	"github.com/wayneashleyberry/lut/pkg/parallel"
+)
+
+// magic identifies files written by Encode.
+const magic = "LUTB"
+
+// version of the file format written by Encode.
+const version = 1
+
+// ErrInvalidBits is returned when the number of bits is outside of 1..8.
+var ErrInvalidBits = errors.New("baked table bits must be between 1 and 8")
+
+// Table is a baked lookup table. Colours are stored as 16 bit values for
+// inputs between 0 and 1, with red changing fastest. Baking is the expensive
+// part, so a batch job should bake or decode a table once and grade every
+// image with it, from as many goroutines as it likes.
+type Table struct {
+	bits int
+	size int
+	data []uint16
+}
+
+// New will bake a table with 2^bits points along each axis by evaluating
+// interp over the cube. Input colours outside of 0..1 are clamped when
+// looking up the table, as are the baked output colours, which makes the table
+// suitable for 8 and 16 bit images. A table with 8 bits uses 96MiB of memory.
+func New(cube colorcube.Cube, interp colorcube.Interpolator, bits int) (*Table, error) {
+	if bits < 1 || bits > 8 {
+		return nil, ErrInvalidBits
+	}
+
+	t := newTable(bits)
+	n := t.size
+	k := float64(n - 1)
+
+	parallel.Line(n, func(start, end int) {
+		for z := start; z < end; z++ {
+			for y := 0; y < n; y++ {
+				for x := 0; x < n; x++ {
+					r, g, b := interp(cube, float64(x)/k, float64(y)/k, float64(z)/k)
+
+					i := (x + n*y + n*n*z) * 3
+					t.data[i+0] = fixed.Encode(r)
+					t.data[i+1] = fixed.Encode(g)
+					t.data[i+2] = fixed.Encode(b)
+				}
+			}
+		}
+	})
+
+	return t, nil
+}
+
+func newTable(bits int) *Table {
+	size := 1 << uint(bits)
+
+	return &Table{
+		bits: bits,
+		size: size,
+		data: make([]uint16, size*size*size*3),
+	}
+}
+
+// Bits will return the number of bits per channel of the table.
+func (t *Table) Bits() int {
+	return t.bits
+}
+
+// Lookup will return the colour for the given input colour, blending the
+// eight baked points surrounding it. Colours which fall on a point are read
+// without blending. It has the signature of a lut.Func.
+func (t *Table) Lookup(r, g, b float64) (float64, float64, float64) {
+	n := t.size
+
+	x, dx := t.cell(r)
+	y, dy := t.cell(g)
+	z, dz := t.cell(b)
+
+	i := (x + n*y + n*n*z) * 3
+	sy := n * 3
+	sz := n * n * 3
+
+	c := t.data
+
+	if dx == 0 && dy == 0 && dz == 0 {
+		return float64(c[i]) / 0xffff, float64(c[i+1]) / 0xffff, float64(c[i+2]) / 0xffff
+	}
+
+	var out [3]float64
+
+	for ch := range out {
+		j := i + ch
+
+		c00 := lerp(c[j], c[j+3], dx)
+		c10 := lerp(c[j+sy], c[j+sy+3], dx)
+		c01 := lerp(c[j+sz], c[j+sz+3], dx)
+		c11 := lerp(c[j+sy+sz], c[j+sy+sz+3], dx)
+
+		c0 := c00 + (c10-c00)*dy
+		c1 := c01 + (c11-c01)*dy
+
+		out[ch] = (c0 + (c1-c0)*dz) / 0xffff
+	}
+
+	return out[0], out[1], out[2]
+}
+
+// cell will return the index of the lower point surrounding v along one axis
+// and the position of v between it and the next point.
+func (t *Table) cell(v float64) (int, float64) {
+	switch {
+	case v <= 0 || math.IsNaN(v):
+		return 0, 0
+	case v >= 1:
+		return t.size - 2, 1
+	}
+
+	f := v * float64(t.size-1)
+	i := int(f)
+
+	if i > t.size-2 {
+		i = t.size - 2
+	}
+
+	return i, f - float64(i)
+}
+
+// Map16 will return the colour for the given 16 bit input colour, like Lookup
+// but with 16.16 fixed point arithmetic, which makes the table a lut.Fixed
+// kernel. 8 bit channels of a table with 8 bits always fall on a point, so
+// they are read directly.
+func (t *Table) Map16(r, g, b uint16) (uint16, uint16, uint16) {
+	n := t.size
+
+	x, dx := t.cell16(r)
+	y, dy := t.cell16(g)
+	z, dz := t.cell16(b)
+
+	i := (x + n*y + n*n*z) * 3
+	c := t.data
+
+	if dx == 0 && dy == 0 && dz == 0 {
+		return c[i], c[i+1], c[i+2]
+	}
+
+	sy := n * 3
+	sz := n * n * 3
+
+	var out [3]uint16
+
+	for ch := range out {
+		j := i + ch
+
+		c00 := fixed.Lerp(int32(c[j]), int32(c[j+3]), dx)
+		c10 := fixed.Lerp(int32(c[j+sy]), int32(c[j+sy+3]), dx)
+		c01 := fixed.Lerp(int32(c[j+sz]), int32(c[j+sz+3]), dx)
+		c11 := fixed.Lerp(int32(c[j+sy+sz]), int32(c[j+sy+sz+3]), dx)
+
+		c0 := fixed.Lerp(c00, c10, dy)
+		c1 := fixed.Lerp(c01, c11, dy)
+
+		out[ch] = fixed.Clamp(int64(fixed.Lerp(c0, c1, dz)))
+	}
+
+	return out[0], out[1], out[2]
+}
+
+// cell16 will return the index of the lower point surrounding a 16 bit value
+// along one axis, and the position of the value between it and the next point
+// in 16.16 fixed point.
+func (t *Table) cell16(v uint16) (int, int32) {
+	f := int64(v) * int64(t.size-1) * fixed.One / 0xffff
+
+	i := int(f >> 16)
+	if i > t.size-2 {
+		return t.size - 2, fixed.One
+	}
+
+	return i, int32(f & (fixed.One - 1))
+}
+
+func lerp(a, b uint16, d float64) float64 {
+	return float64(a) + (float64(b)-float64(a))*d
+}
+
+// Apply will apply color transformations to the provided image using the
+// baked table (taking the intensity multiplier into account). 8 and 16 bit
+// images are graded with Map16, see lut.WithFixed.
+func (t *Table) Apply(src image.Image, intensity float64, opts ...lut.Option) (image.Image, error) {
+	return lut.Apply(src, t.Lookup, intensity, append([]lut.Option{lut.WithFixed(t)}, opts...)...)
+}
+
+// ApplyTo will apply color transformations to the rectangle r of dst using the
+// baked table, reading from src starting at sp (taking the intensity
+// multiplier into account). See Apply and lut.ApplyTo for details.
+func (t *Table) ApplyTo(dst draw.Image, r image.Rectangle, src image.Image, sp image.Point, intensity float64, opts ...lut.Option) error {
+	return lut.ApplyTo(dst, r, src, sp, t.Lookup, intensity, append([]lut.Option{lut.WithFixed(t)}, opts...)...)
+}
+
+// Encode will write the table in a compact binary format which can be read
+// back with Decode.
+func (t *Table) Encode(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	if _, err := bw.WriteString(magic); err != nil {
+		return err
+	}
+
+	if _, err := bw.Write([]byte{version, byte(t.bits)}); err != nil {
+		return err
+	}
+
+	if err := binary.Write(bw, binary.LittleEndian, t.data); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+// Decode will read a table written by Encode.
+func Decode(r io.Reader) (*Table, error) {
+	br := bufio.NewReader(r)
+
+	var header [6]byte
+
+	if _, err := io.ReadFull(br, header[:]); err != nil {
+		return nil, err
+	}
+
+	if string(header[:4]) != magic {
+		return nil, errors.New("invalid baked table header")
+	}
+
+	if header[4] != version {
+		return nil, errors.New("unsupported baked table version")
+	}
+
+	bits := int(header[5])
+	if bits < 1 || bits > 8 {
+		return nil, ErrInvalidBits
+	}
+
+	t := newTable(bits)
+
+	if err := binary.Read(br, binary.LittleEndian, t.data); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
@@ -0,0 +1,210 @@
+package baked
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/wayneashleyberry/lut/pkg/colorcube"
+	"github.com/wayneashleyberry/lut/pkg/fixed"
+	"github.com/wayneashleyberry/lut/pkg/interptest"
+	"github.com/wayneashleyberry/lut/pkg/tetrahedral"
+	"github.com/wayneashleyberry/lut/pkg/trilinear"
+)
+
+func TestNew(t *testing.T) {
+	if _, err := New(interptest.Cube(2, interptest.Identity), trilinear.Lookup, 0); err != ErrInvalidBits {
+		t.Errorf("New() error = %v, want %v", err, ErrInvalidBits)
+	}
+
+	if _, err := New(interptest.Cube(2, interptest.Identity), trilinear.Lookup, 9); err != ErrInvalidBits {
+		t.Errorf("New() error = %v, want %v", err, ErrInvalidBits)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name   string
+		bits   int
+		interp colorcube.Interpolator
+		fn     func(r, g, b float64) (float64, float64, float64)
+		max    float64 // code values
+	}{
+		{"identity", 2, trilinear.Lookup, interptest.Identity, 0.01},
+		{"matrix", 4, tetrahedral.Lookup, interptest.CrossTalk, 0.01},
+		{"gamma 5 bits", 5, trilinear.Lookup, interptest.Gamma(2.2), 1},
+		{"gamma 6 bits", 6, trilinear.Lookup, interptest.Gamma(2.2), 0.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cube := interptest.Cube(33, tt.fn)
+
+			table, err := New(cube, tt.interp, tt.bits)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var max float64
+
+			for r := 0; r < 256; r += 3 {
+				for g := 0; g < 256; g += 5 {
+					for b := 0; b < 256; b += 7 {
+						rf, gf, bf := float64(r)/0xff, float64(g)/0xff, float64(b)/0xff
+
+						r0, g0, b0 := tt.interp(cube, rf, gf, bf)
+						r1, g1, b1 := table.Lookup(rf, gf, bf)
+
+						max = math.Max(max, math.Abs(r0-r1))
+						max = math.Max(max, math.Abs(g0-g1))
+						max = math.Max(max, math.Abs(b0-b1))
+					}
+				}
+			}
+
+			if max*0xff > tt.max {
+				t.Errorf("Lookup() max error = %v code values, want at most %v", max*0xff, tt.max)
+			}
+		})
+	}
+}
+
+func TestLookup8(t *testing.T) {
+	cube := interptest.Cube(33, interptest.Gamma(2.2))
+
+	table, err := New(cube, tetrahedral.Lookup, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every 8 bit colour falls on a point of the table, so it reproduces the
+	// interpolator up to the 16 bit precision of the table.
+	for r := 0; r < 256; r += 3 {
+		for g := 0; g < 256; g += 5 {
+			for b := 0; b < 256; b += 7 {
+				rf, gf, bf := float64(r)/0xff, float64(g)/0xff, float64(b)/0xff
+
+				r0, g0, b0 := tetrahedral.Lookup(cube, rf, gf, bf)
+				want := [3]uint16{fixed.Encode(r0), fixed.Encode(g0), fixed.Encode(b0)}
+
+				r1, g1, b1 := table.Lookup(rf, gf, bf)
+				if got := [3]float64{r1, g1, b1}; got != [3]float64{float64(want[0]) / 0xffff, float64(want[1]) / 0xffff, float64(want[2]) / 0xffff} {
+					t.Fatalf("Lookup(%d, %d, %d) = %v, want %v", r, g, b, got, want)
+				}
+
+				r2, g2, b2 := table.Map16(uint16(r*0x101), uint16(g*0x101), uint16(b*0x101))
+				if got := [3]uint16{r2, g2, b2}; got != want {
+					t.Fatalf("Map16(%d, %d, %d) = %v, want %v", r, g, b, got, want)
+				}
+			}
+		}
+	}
+
+	src := interptest.Gradient(256, 64)
+
+	got, err := table.Apply(src, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := tetrahedral.Interpolate(src, cube, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := interptest.Diff(got, want); d != 0 {
+		t.Errorf("Apply() differs from the interpolator by %d code values", d)
+	}
+}
+
+func TestMap16(t *testing.T) {
+	table, err := New(interptest.Cube(17, interptest.CrossTalk), trilinear.Lookup, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range [][3]uint16{{0, 0, 0}, {0xffff, 0xffff, 0xffff}, {0x1234, 0x8000, 0xfedc}, {1, 0xfffe, 0x4321}} {
+		r0, g0, b0 := table.Lookup(float64(v[0])/0xffff, float64(v[1])/0xffff, float64(v[2])/0xffff)
+		r1, g1, b1 := table.Map16(v[0], v[1], v[2])
+
+		for i, d := range []float64{r0*0xffff - float64(r1), g0*0xffff - float64(g1), b0*0xffff - float64(b1)} {
+			if math.Abs(d) > 2 {
+				t.Errorf("Map16(%v) channel %d differs from Lookup() by %v", v, i, d)
+			}
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	table, err := New(interptest.Cube(17, interptest.Gamma(2.2)), trilinear.Lookup, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+
+	if err := table.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Bits() != table.Bits() {
+		t.Fatalf("Decode() bits = %d, want %d", got.Bits(), table.Bits())
+	}
+
+	for i := range table.data {
+		if got.data[i] != table.data[i] {
+			t.Fatalf("Decode() data[%d] = %d, want %d", i, got.data[i], table.data[i])
+		}
+	}
+
+	if _, err := Decode(bytes.NewReader([]byte("LUTA\x01\x04"))); err == nil {
+		t.Error("Decode() expected an error for an invalid header")
+	}
+
+	if _, err := Decode(bytes.NewReader([]byte("LUTB\x01\x04\x00"))); err == nil {
+		t.Error("Decode() expected an error for truncated data")
+	}
+}
+
+func BenchmarkTrilinear(b *testing.B) {
+	src := interptest.Gradient(512, 512)
+	cube := interptest.Cube(33, interptest.Gamma(2.2))
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := trilinear.Interpolate(src, cube, 1); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTable6(b *testing.B) {
+	benchmarkTable(b, 6)
+}
+
+func BenchmarkTable8(b *testing.B) {
+	benchmarkTable(b, 8)
+}
+
+func benchmarkTable(b *testing.B, bits int) {
+	src := interptest.Gradient(512, 512)
+
+	table, err := New(interptest.Cube(33, interptest.Gamma(2.2)), trilinear.Lookup, bits)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := table.Apply(src, 1); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -1,46 +1,289 @@
 // Package colorcube implements a very simple data structure for a 3d color cube
 // and contains helper methods for getting and settings values at specific
-// points. The underlying data structure is a multidimensional map which needs
-// to be initialised to a specific size to keep things efficient.
+// points. The lattice is stored in a single flat slice, in the same order as
+// the points of a .cube file.
 package colorcube
 
+import (
+	"errors"
+	"math"
+	"strings"
+)
+
 // Cube implementation.
+//
+// Data holds Size*Size*Size points of three channels each, the red, green and
+// blue values of a point are stored next to each other. The red index changes
+// fastest, followed by green and then blue, so the point (x, y, z) starts at
+// Data[3*(x + Size*y + Size*Size*z)].
 type Cube struct {
 	Size      int
-	Data      map[int]map[int]map[int][]float64
+	Data      []float64
 	DomainMin []float64
 	DomainMax []float64
+
+	// DomainPolicy controls how colours outside of the domain are looked up.
+	DomainPolicy DomainPolicy
 }
 
-// New will create a new Cube struct with the given size.
-func New(size int, dmin, dmax []float64) Cube {
-	d := make(map[int]map[int]map[int][]float64, size)
+// DomainPolicy selects how a cube looks up colours outside of its domain, for
+// example floating point colours brighter than white, or any colour beyond a
+// reduced DOMAIN_MAX.
+type DomainPolicy int
 
-	for x := 0; x < size; x++ {
-		yy := make(map[int]map[int][]float64, size)
+// Supported domain policies.
+const (
+	// Clamp moves colours to the nearest edge of the domain, this is the
+	// default.
+	Clamp DomainPolicy = iota
+	// Extrapolate continues the colours at the edge of the domain linearly,
+	// with the slope of the last cell along every axis.
+	Extrapolate
+	// SoftClip extrapolates like Extrapolate, but compresses the distance
+	// beyond the domain onto a smooth shoulder, so colours level off at most
+	// half a cell past its edges. Colours inside of the domain are looked up
+	// exactly as with Clamp.
+	SoftClip
+)
 
-		for y := 0; y < size; y++ {
-			z := make(map[int][]float64, size)
-			yy[y] = z
-		}
+// String will return the name of the policy, as written to .cube files.
+func (p DomainPolicy) String() string {
+	switch p {
+	case Extrapolate:
+		return "extrapolate"
+	case SoftClip:
+		return "soft-clip"
+	default:
+		return "clamp"
+	}
+}
 
-		d[x] = yy
+// ParseDomainPolicy will return the policy with the given name, see
+// DomainPolicy.String.
+func ParseDomainPolicy(s string) (DomainPolicy, error) {
+	for _, p := range []DomainPolicy{Clamp, Extrapolate, SoftClip} {
+		if strings.EqualFold(s, p.String()) {
+			return p, nil
+		}
 	}
 
+	return Clamp, ErrInvalidDomainPolicy
+}
+
+// ErrInvalidDomainPolicy is returned for unknown domain policy names.
+var ErrInvalidDomainPolicy = errors.New("invalid domain policy, accepted values are `clamp`, `extrapolate` and `soft-clip`")
+
+// New will create a new Cube struct with the given size.
+func New(size int, dmin, dmax []float64) Cube {
 	return Cube{
 		Size:      size,
-		Data:      d,
+		Data:      make([]float64, size*size*size*3),
 		DomainMin: dmin,
 		DomainMax: dmax,
 	}
 }
 
-// Get will return the color at a given point.
+// Len will return the number of points in the cube.
+func (c Cube) Len() int {
+	return c.Size * c.Size * c.Size
+}
+
+// Index will return the index of a point, as used by At.
+func (c Cube) Index(x, y, z int) int {
+	return x + c.Size*y + c.Size*c.Size*z
+}
+
+// At will return the color of the point with the given index. The result
+// shares its storage with the cube.
+func (c Cube) At(i int) []float64 {
+	return c.Data[3*i : 3*i+3 : 3*i+3]
+}
+
+// Get will return the color at a given point. The result shares its storage
+// with the cube.
 func (c Cube) Get(x, y, z int) []float64 {
-	return c.Data[x][y][z]
+	return c.At(c.Index(x, y, z))
 }
 
-// Set will set a color for the given point.
+// Set will set a color for the given point, copying the first three values
+// of val.
 func (c Cube) Set(x, y, z int, val []float64) {
-	c.Data[x][y][z] = val
+	copy(c.At(c.Index(x, y, z)), val[:3])
+}
+
+// Domain will return the lower and upper bound of the input domain along one
+// axis of the cube (0 for red, 1 for green and 2 for blue), defaulting to 0..1.
+func (c Cube) Domain(axis int) (float64, float64) {
+	min, max := 0.0, 1.0
+
+	if axis < len(c.DomainMin) {
+		min = c.DomainMin[axis]
+	}
+
+	if axis < len(c.DomainMax) {
+		max = c.DomainMax[axis]
+	}
+
+	return min, max
+}
+
+// Cell will locate a value from the domain of the cube along one axis (0 for
+// red, 1 for green and 2 for blue) and return the indices of the two lattice
+// points surrounding it, along with the position of the value between them.
+//
+// The domain maps linearly onto the lattice, so DOMAIN_MIN falls on the first
+// point and DOMAIN_MAX on the last. Values outside of the domain are clamped
+// to its edges, interpolators extrapolate before locating cells, see
+// Extrapolate.
+func (c Cube) Cell(axis int, v float64) (int, int, float64) {
+	if c.Size < 2 {
+		return 0, 0, 0
+	}
+
+	min, max := c.Domain(axis)
+
+	f := (v - min) / (max - min) * float64(c.Size-1)
+
+	switch {
+	case f <= 0 || math.IsNaN(f):
+		return 0, 1, 0
+	case f >= float64(c.Size-1):
+		return c.Size - 2, c.Size - 1, 1
+	}
+
+	i := int(f)
+
+	return i, i + 1, f - float64(i)
+}
+
+// Soften will move a value beyond the domain of a cube which uses SoftClip
+// onto an exponential shoulder past the edge, along one axis. The shoulder
+// meets the edge with a slope of 1 and levels off half a cell beyond it, or a
+// tenth of the domain for cubes with fewer than 5 cells. Values inside of the
+// domain, and values of cubes with other policies, are returned unchanged.
+func (c Cube) Soften(axis int, v float64) float64 {
+	if c.DomainPolicy != SoftClip || c.Size < 2 {
+		return v
+	}
+
+	min, max := c.Domain(axis)
+	k := math.Min(0.5/float64(c.Size-1), 0.1) * (max - min)
+
+	switch {
+	case v > max:
+		return max + k*(1-math.Exp((max-v)/k))
+	case v < min:
+		return min - k*(1-math.Exp((v-min)/k))
+	default:
+		return v
+	}
+}
+
+// Extrapolate will look up a colour outside of the domain of a cube which
+// uses the Extrapolate or SoftClip policy. The colour at the nearest point in
+// the domain is found with interp, and continued linearly along every axis
+// which is out of range, with the slope interp gives over the last cell.
+// SoftClip cubes first move the colour onto their shoulder, see Soften. It
+// returns false for colours inside of the domain and for the Clamp policy,
+// interp should then locate the colour itself.
+//
+// Interpolators call Extrapolate first, passing themselves as interp.
+func (c Cube) Extrapolate(interp Interpolator, r, g, b float64) (float64, float64, float64, bool) {
+	if c.DomainPolicy == Clamp || c.Size < 2 {
+		return 0, 0, 0, false
+	}
+
+	p := [3]float64{r, g, b}
+	q := p
+	out := false
+
+	for axis, v := range p {
+		if math.IsNaN(v) {
+			return 0, 0, 0, false
+		}
+
+		v = c.Soften(axis, v)
+		p[axis] = v
+
+		min, max := c.Domain(axis)
+		q[axis] = math.Min(math.Max(v, min), max)
+		out = out || q[axis] != v
+	}
+
+	if !out {
+		return 0, 0, 0, false
+	}
+
+	var base [3]float64
+
+	base[0], base[1], base[2] = interp(c, q[0], q[1], q[2])
+	rgb := base
+
+	for axis, v := range p {
+		if v == q[axis] {
+			continue
+		}
+
+		// Step one cell back into the domain.
+		min, max := c.Domain(axis)
+		step := (max - min) / float64(c.Size-1)
+
+		if v > max {
+			step = -step
+		}
+
+		s := q
+		s[axis] += step
+
+		var in [3]float64
+
+		in[0], in[1], in[2] = interp(c, s[0], s[1], s[2])
+
+		for i := range rgb {
+			rgb[i] += (base[i] - in[i]) / -step * (v - q[axis])
+		}
+	}
+
+	return rgb[0], rgb[1], rgb[2], true
+}
+
+// Interpolator will return the colour at a point in the domain of the cube,
+// estimating it from the surrounding points on the lattice.
+type Interpolator func(c Cube, r, g, b float64) (float64, float64, float64)
+
+// Nearest is an Interpolator which returns the colour of the lattice point
+// closest to the given point, without any interpolation.
+func Nearest(c Cube, r, g, b float64) (float64, float64, float64) {
+	if r, g, b, ok := c.Extrapolate(Nearest, r, g, b); ok {
+		return r, g, b
+	}
+
+	rgb := c.Get(c.nearest(0, r), c.nearest(1, g), c.nearest(2, b))
+
+	return rgb[0], rgb[1], rgb[2]
+}
+
+func (c Cube) nearest(axis int, v float64) int {
+	i0, i1, d := c.Cell(axis, v)
+	if d >= 0.5 {
+		return i1
+	}
+
+	return i0
+}
+
+// Transform maps colours through a cube using an interpolator, it implements
+// lut.Transform. A nil Interpolator uses Nearest.
+type Transform struct {
+	Cube         Cube
+	Interpolator Interpolator
+}
+
+// Map will return the colour at the given point in the domain of the cube.
+func (t Transform) Map(r, g, b float64) (float64, float64, float64) {
+	if t.Interpolator == nil {
+		return Nearest(t.Cube, r, g, b)
+	}
+
+	return t.Interpolator(t.Cube, r, g, b)
 }
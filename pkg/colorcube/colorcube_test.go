@@ -1,6 +1,7 @@
 package colorcube
 
 import (
+	"math"
 	"reflect"
 	"testing"
 )
@@ -15,3 +16,156 @@ func TestCube_Get(t *testing.T) {
 		t.Errorf("Cube.Get() = %v, want %v", got, want)
 	}
 }
+
+func TestCube_Index(t *testing.T) {
+	cube := New(3, nil, nil)
+
+	if len(cube.Data) != 3*3*3*3 || cube.Len() != 27 {
+		t.Fatalf("New() has %d values and %d points", len(cube.Data), cube.Len())
+	}
+
+	cube.Set(2, 1, 0, []float64{0.1, 0.2, 0.3})
+
+	if i := cube.Index(2, 1, 0); i != 5 {
+		t.Errorf("Cube.Index() = %d, want 5", i)
+	}
+
+	if got, want := cube.Data[15:18], []float64{0.1, 0.2, 0.3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Cube.Data[15:18] = %v, want %v", got, want)
+	}
+
+	// At shares storage with the cube.
+	cube.At(5)[1] = 0.5
+
+	if got := cube.Get(2, 1, 0)[1]; got != 0.5 {
+		t.Errorf("Cube.Get() = %v after changing At(), want 0.5", got)
+	}
+
+	if got := cube.At(5); len(append(got, 1)) != 4 || cube.Data[18] != 0 {
+		t.Error("appending to Cube.At() changed the next point")
+	}
+}
+
+func TestCube_Cell(t *testing.T) {
+	cube := New(5, []float64{0, -1, 0}, []float64{1, 1, 2})
+
+	tests := []struct {
+		axis   int
+		v      float64
+		i0, i1 int
+		d      float64
+	}{
+		{0, 0, 0, 1, 0},
+		{0, 0.5, 2, 3, 0},
+		{0, 1, 3, 4, 1},
+		{1, -1, 0, 1, 0},
+		{1, 0, 2, 3, 0},
+		{1, 0.75, 3, 4, 0.5},
+		{2, 1, 2, 3, 0},
+		{2, 1.25, 2, 3, 0.5},
+		{2, -0.5, 0, 1, 0},
+		{2, 3, 3, 4, 1},
+	}
+
+	for _, tt := range tests {
+		i0, i1, d := cube.Cell(tt.axis, tt.v)
+		if i0 != tt.i0 || i1 != tt.i1 || d != tt.d {
+			t.Errorf("Cube.Cell(%d, %v) = %d, %d, %v, want %d, %d, %v", tt.axis, tt.v, i0, i1, d, tt.i0, tt.i1, tt.d)
+		}
+	}
+}
+
+func TestCube_Soften(t *testing.T) {
+	cube := New(5, nil, nil)
+	cube.DomainPolicy = SoftClip
+
+	// The domain, including its edges, is left untouched.
+	for _, v := range []float64{0, 0.01, 0.5, 0.99, 1} {
+		if got := cube.Soften(0, v); got != v {
+			t.Errorf("Cube.Soften(%v) = %v, want %v", v, got, v)
+		}
+	}
+
+	if i0, i1, d := cube.Cell(0, 1); i0 != 3 || i1 != 4 || d != 1 {
+		t.Errorf("Cube.Cell(1) = %d, %d, %v, want 3, 4, 1", i0, i1, d)
+	}
+
+	// Beyond the domain values level off half a cell past the edge.
+	prev := math.Inf(-1)
+
+	for v := -1.0; v <= 2; v += 0.001 {
+		got := cube.Soften(0, v)
+
+		if got <= prev || got <= -0.125 || got >= 1.125 {
+			t.Fatalf("Cube.Soften(%v) = %v, after %v", v, got, prev)
+		}
+
+		prev = got
+	}
+
+	// Values beyond the domain are extrapolated from their soft position.
+	for x := 0; x < 5; x++ {
+		for y := 0; y < 5; y++ {
+			for z := 0; z < 5; z++ {
+				cube.Set(x, y, z, []float64{float64(x) / 4, float64(y) / 4, float64(z) / 4})
+			}
+		}
+	}
+
+	r, _, _, ok := cube.Extrapolate(Nearest, 3, 0.5, 0.5)
+	if want := cube.Soften(0, 3); !ok || math.Abs(r-want) > 1e-12 {
+		t.Errorf("Cube.Extrapolate(3) = %v, %v, want %v", r, ok, want)
+	}
+
+	if cube.DomainPolicy = Clamp; cube.Soften(0, 3) != 3 {
+		t.Error("Cube.Soften() should only apply to the soft clip policy")
+	}
+}
+
+func TestCube_Extrapolate(t *testing.T) {
+	cube := New(3, []float64{0, 0, 0}, []float64{1, 2, 1})
+
+	for x := 0; x < 3; x++ {
+		for y := 0; y < 3; y++ {
+			for z := 0; z < 3; z++ {
+				cube.Set(x, y, z, []float64{float64(x), float64(y * y), float64(z)})
+			}
+		}
+	}
+
+	if _, _, _, ok := cube.Extrapolate(Nearest, 2, 0, 0); ok {
+		t.Error("Cube.Extrapolate() with the clamp policy should return false")
+	}
+
+	cube.DomainPolicy = Extrapolate
+
+	tests := []struct {
+		in   [3]float64
+		want [3]float64
+		ok   bool
+	}{
+		{[3]float64{0.5, 1, 0.5}, [3]float64{}, false},
+		{[3]float64{1.5, 1, 0.5}, [3]float64{3, 1, 1}, true},
+		{[3]float64{-1, 3, 1}, [3]float64{-2, 7, 2}, true},
+		{[3]float64{math.NaN(), 0, 0}, [3]float64{}, false},
+	}
+
+	for _, tt := range tests {
+		r, g, b, ok := cube.Extrapolate(Nearest, tt.in[0], tt.in[1], tt.in[2])
+		if ok != tt.ok || ok && [3]float64{r, g, b} != tt.want {
+			t.Errorf("Cube.Extrapolate(%v) = %v, %v, %v, %v, want %v, %v", tt.in, r, g, b, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseDomainPolicy(t *testing.T) {
+	for _, p := range []DomainPolicy{Clamp, Extrapolate, SoftClip} {
+		if got, err := ParseDomainPolicy(p.String()); got != p || err != nil {
+			t.Errorf("ParseDomainPolicy(%q) = %v, %v", p.String(), got, err)
+		}
+	}
+
+	if _, err := ParseDomainPolicy("wrap"); err != ErrInvalidDomainPolicy {
+		t.Errorf("ParseDomainPolicy() error = %v, want %v", err, ErrInvalidDomainPolicy)
+	}
+}
@@ -6,24 +6,24 @@ import (
 	"errors"
 	"fmt"
 	"image"
-	"image/color"
+	"image/draw"
 	"io"
-	"math"
 	"strconv"
 	"strings"
 
 	"github.com/wayneashleyberry/lut/pkg/colorcube"
-	"github.com/wayneashleyberry/lut/pkg/parallel"
+	"github.com/wayneashleyberry/lut/pkg/lut"
 	"github.com/wayneashleyberry/lut/pkg/util"
 )
 
 // CubeFile implementation.
 type CubeFile struct {
 	Dimensions int
-	DomainMax  []float64 // DOMAIN_MAX
-	DomainMin  []float64 // DOMAIN_MIN
-	Size       int       // LUT_3D_SIZE
-	Title      string    // TITLE
+	DomainMax  []float64              // DOMAIN_MAX
+	DomainMin  []float64              // DOMAIN_MIN
+	Size       int                    // LUT_3D_SIZE
+	Title      string                 // TITLE
+	Policy     colorcube.DomainPolicy // # DOMAIN_POLICY
 	R          []float64
 	G          []float64
 	B          []float64
@@ -31,25 +31,28 @@ type CubeFile struct {
 
 // FromColorCube will create a cube file from a color cube.
 func FromColorCube(cube colorcube.Cube) CubeFile {
-	r := make([]float64, cube.Size*cube.Size*cube.Size)
-	g := make([]float64, cube.Size*cube.Size*cube.Size)
-	b := make([]float64, cube.Size*cube.Size*cube.Size)
-
-	for i := 0; i < cube.Size*cube.Size*cube.Size; i++ {
-		x := i % cube.Size
-		y := i / cube.Size % cube.Size
-		z := i / cube.Size / cube.Size
-		rgb := cube.Get(x, y, z)
-		r[i] = rgb[0]
-		g[i] = rgb[1]
-		b[i] = rgb[2]
+	n := cube.Len()
+	r := make([]float64, n)
+	g := make([]float64, n)
+	b := make([]float64, n)
+
+	for i := 0; i < n; i++ {
+		r[i], g[i], b[i] = cube.Data[3*i], cube.Data[3*i+1], cube.Data[3*i+2]
+	}
+
+	dmin := make([]float64, 3)
+	dmax := make([]float64, 3)
+
+	for i := range dmin {
+		dmin[i], dmax[i] = cube.Domain(i)
 	}
 
 	return CubeFile{
 		Dimensions: 3,
-		DomainMax:  cube.DomainMax,
-		DomainMin:  cube.DomainMin,
+		DomainMax:  dmax,
+		DomainMin:  dmin,
 		Size:       cube.Size,
+		Policy:     cube.DomainPolicy,
 		R:          r,
 		G:          g,
 		B:          b,
@@ -76,8 +79,20 @@ func Parse(r io.Reader) (CubeFile, error) {
 			continue
 		}
 
-		// Skip comments
+		// Skip comments, except for the domain policy which is stored in a
+		// comment so that other applications ignore it.
 		if strings.HasPrefix(line, "#") {
+			s := strings.TrimSpace(strings.TrimPrefix(line, "#"))
+
+			if strings.HasPrefix(s, "DOMAIN_POLICY") {
+				p, err := colorcube.ParseDomainPolicy(strings.TrimSpace(strings.TrimPrefix(s, "DOMAIN_POLICY")))
+				if err != nil {
+					return o, err
+				}
+
+				o.Policy = p
+			}
+
 			continue
 		}
 
@@ -152,33 +167,41 @@ func Parse(r io.Reader) (CubeFile, error) {
 		return o, errors.New("invalid lut size")
 	}
 
+	for i := range o.DomainMin {
+		if o.DomainMin[i] >= o.DomainMax[i] {
+			return o, errors.New("domain min must be less than domain max")
+		}
+	}
+
 	return o, nil
 }
 
 // Cube will convert a cube file into a color cube.
 func (cf CubeFile) Cube() colorcube.Cube {
 	cube := colorcube.New(cf.Size, cf.DomainMin, cf.DomainMax)
+	cube.DomainPolicy = cf.Policy
 
-	for i := 0; i < cf.Size*cf.Size*cf.Size; i++ {
-		x := i % cf.Size
-		y := i / cf.Size % cf.Size
-		z := i / cf.Size / cf.Size
-		cube.Set(x, y, z, []float64{cf.R[i], cf.G[i], cf.B[i]})
+	for i := 0; i < cube.Len(); i++ {
+		cube.Data[3*i], cube.Data[3*i+1], cube.Data[3*i+2] = cf.R[i], cf.G[i], cf.B[i]
 	}
 
 	return cube
 }
 
-// Bytes implementation.
+// Bytes will encode the cube file in the .cube format.
 func (cf CubeFile) Bytes() []byte {
 	var b bytes.Buffer
 
 	fmt.Fprintf(&b, `TITLE "%s"
 LUT_3D_SIZE %d
-DOMAIN_MIN %.1f %.1f %.1f
-DOMAIN_MAX %.1f %.1f %.1f
+DOMAIN_MIN %.6f %.6f %.6f
+DOMAIN_MAX %.6f %.6f %.6f
 `, cf.Title, cf.Size, cf.DomainMin[0], cf.DomainMin[1], cf.DomainMin[2], cf.DomainMax[0], cf.DomainMax[1], cf.DomainMax[2])
 
+	if cf.Policy != colorcube.Clamp {
+		fmt.Fprintf(&b, "# DOMAIN_POLICY %s\n", cf.Policy)
+	}
+
 	for i := range cf.R {
 		fmt.Fprintf(&b, "%.6f %.6f %.6f\n", cf.R[i], cf.G[i], cf.B[i])
 	}
@@ -186,49 +209,23 @@ DOMAIN_MAX %.1f %.1f %.1f
 	return b.Bytes()
 }
 
-// Apply implementation.
-func (cf CubeFile) Apply(src image.Image, intensity float64) (image.Image, error) {
-	if intensity < 0 || intensity > 1 {
-		return src, errors.New("intensity must be between 0 and 1")
-	}
-
-	bounds := src.Bounds()
-
-	out := image.NewNRGBA(image.Rectangle{
-		image.Point{0, 0},
-		image.Point{bounds.Max.X, bounds.Max.Y},
-	})
-
-	space := &image.NRGBA{}
-	model := space.ColorModel()
-
-	N := float64(cf.Size)
-
-	width, height := bounds.Dx(), bounds.Dy()
-	parallel.Line(height, func(start, end int) {
-		for y := start; y < end; y++ {
-			for x := 0; x < width; x++ {
-				px := src.At(x, y)
-				c := model.Convert(px).(color.NRGBA)
+// Apply will adjust the colours of src using the nearest point in the cube,
+// without any interpolation.
+func (cf CubeFile) Apply(src image.Image, intensity float64, opts ...lut.Option) (image.Image, error) {
+	cube := cf.Cube()
 
-				r := math.Floor((float64(c.R) / 255.0) * (N - 1))
-				g := math.Floor((float64(c.G) / 255.0) * (N - 1))
-				b := math.Floor((float64(c.B) / 255.0) * (N - 1))
-
-				i := r + N*g + N*N*b
-
-				lr, lg, lb := uint8(cf.R[int(i)]*255), uint8(cf.G[int(i)]*255), uint8(cf.B[int(i)]*255)
-
-				o := color.NRGBA{}
-				o.R = uint8(float64(c.R)*(1-intensity) + float64(lr)*intensity)
-				o.G = uint8(float64(c.G)*(1-intensity) + float64(lg)*intensity)
-				o.B = uint8(float64(c.B)*(1-intensity) + float64(lb)*intensity)
-				o.A = c.A
+	return lut.Apply(src, func(r, g, b float64) (float64, float64, float64) {
+		return colorcube.Nearest(cube, r, g, b)
+	}, intensity, opts...)
+}
 
-				out.Set(x, y, o)
-			}
-		}
-	})
+// ApplyTo will adjust the colours of the rectangle r of dst using the nearest
+// point in the cube, reading from src starting at sp. See lut.ApplyTo for
+// details.
+func (cf CubeFile) ApplyTo(dst draw.Image, r image.Rectangle, src image.Image, sp image.Point, intensity float64, opts ...lut.Option) error {
+	cube := cf.Cube()
 
-	return out, nil
+	return lut.ApplyTo(dst, r, src, sp, func(r, g, b float64) (float64, float64, float64) {
+		return colorcube.Nearest(cube, r, g, b)
+	}, intensity, opts...)
 }
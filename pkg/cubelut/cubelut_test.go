@@ -1,10 +1,14 @@
 package cubelut
 
 import (
+	"bytes"
 	"io"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/wayneashleyberry/lut/pkg/colorcube"
 )
 
 func TestParse(t *testing.T) {
@@ -52,3 +56,60 @@ func TestParse(t *testing.T) {
 		})
 	}
 }
+
+func TestCubeFile_Bytes(t *testing.T) {
+	lut, err := os.Open("./testdata/testlut.cube")
+	if err != nil {
+		t.Fatal("could not open file")
+	}
+	defer lut.Close()
+
+	want, err := Parse(lut)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Parse(bytes.NewReader(FromColorCube(want.Cube()).Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got.Title = want.Title
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse(Bytes()) = %v, want %v", got, want)
+	}
+}
+
+func TestParseDomain(t *testing.T) {
+	_, err := Parse(strings.NewReader("LUT_3D_SIZE 2\nDOMAIN_MIN 0 0 1\nDOMAIN_MAX 1 1 1\n"))
+	if err == nil {
+		t.Error("Parse() expected an error for an empty domain")
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	for _, policy := range []colorcube.DomainPolicy{colorcube.Clamp, colorcube.Extrapolate, colorcube.SoftClip} {
+		cube := colorcube.New(2, nil, nil)
+		cube.DomainPolicy = policy
+
+		b := FromColorCube(cube).Bytes()
+
+		if got := bytes.Contains(b, []byte("DOMAIN_POLICY")); got != (policy != colorcube.Clamp) {
+			t.Errorf("Bytes() for %v contains a domain policy: %v", policy, got)
+		}
+
+		cf, err := Parse(bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := cf.Cube().DomainPolicy; got != policy {
+			t.Errorf("Parse(Bytes()) policy = %v, want %v", got, policy)
+		}
+	}
+
+	if _, err := Parse(strings.NewReader("# DOMAIN_POLICY wrap\nLUT_3D_SIZE 2\n")); err != colorcube.ErrInvalidDomainPolicy {
+		t.Errorf("Parse() error = %v, want %v", err, colorcube.ErrInvalidDomainPolicy)
+	}
+}
@@ -0,0 +1,150 @@
+// Package fixed implements a colour cube lattice for integer interpolation
+// kernels. Colours are stored with 16 bits per channel and positions inside
+// the cube are 16.16 fixed point numbers, so looking up a colour needs no
+// floating point arithmetic and no allocations.
+package fixed
+
+import (
+	"math"
+
+	"github.com/wayneashleyberry/lut/pkg/colorcube"
+)
+
+// One is 1.0 in 16.16 fixed point.
+const One = 1 << 16
+
+// Lattice holds the points of a colour cube as 16 bit values, with red
+// changing fastest. Kernels only read from their lattice, so one kernel can
+// grade the rows of an image from every worker at once.
+type Lattice struct {
+	Size int
+	Data []uint16
+
+	// mul and add map a 16 bit channel value onto a 16.16 fixed point
+	// position on the lattice, scaled by a further 2^16 for precision.
+	mul [3]int64
+	add [3]int64
+
+	policy colorcube.DomainPolicy
+
+	// soft holds the 16.16 fixed point position of every 16 bit channel
+	// value for cubes which use colorcube.SoftClip, see colorcube.Cube.Soften.
+	soft [3][]int32
+}
+
+// NewLattice will convert a cube to a lattice. Colours outside of 0..1 are
+// clamped, which makes the lattice suitable for 8 and 16 bit images. The
+// domain policy of the cube is kept, soft clipped cubes use a table of 768KiB
+// to locate channel values without floating point arithmetic.
+func NewLattice(cube colorcube.Cube) *Lattice {
+	n := cube.Size
+
+	l := &Lattice{
+		Size:   n,
+		Data:   make([]uint16, n*n*n*3),
+		policy: cube.DomainPolicy,
+	}
+
+	// The lattice has the same layout as the cube.
+	for i, v := range cube.Data {
+		l.Data[i] = Encode(v)
+	}
+
+	for axis := range l.mul {
+		min, max := cube.Domain(axis)
+		k := float64(n-1) / (max - min)
+
+		l.mul[axis] = int64(math.Round(k / 0xffff * One * One))
+		l.add[axis] = int64(math.Round(-min * k * One * One))
+
+		if l.policy == colorcube.SoftClip && n >= 2 {
+			l.soft[axis] = make([]int32, 0x10000)
+
+			for v := range l.soft[axis] {
+				f := (cube.Soften(axis, float64(v)/0xffff) - min) * k
+				l.soft[axis][v] = int32(math.Round(f * One))
+			}
+		}
+	}
+
+	return l
+}
+
+// Index will return the offset in Data of the red channel of a point.
+func (l *Lattice) Index(x, y, z int) int {
+	return (x + l.Size*y + l.Size*l.Size*z) * 3
+}
+
+// Cell will locate a 16 bit channel value along one axis of the lattice (0
+// for red, 1 for green and 2 for blue) and return the indices of the two
+// points surrounding it, along with the position of the value between them
+// in 16.16 fixed point. It behaves like colorcube.Cube.Cell, values outside of
+// the domain are clamped to its edges. Lattices of cubes which use
+// colorcube.Extrapolate or colorcube.SoftClip return positions before the
+// first cell or beyond the last one instead, so the kernels extend the
+// boundary cells linearly.
+func (l *Lattice) Cell(axis int, v uint16) (int, int, int32) {
+	if l.Size < 2 {
+		return 0, 0, 0
+	}
+
+	var f int64
+
+	if l.soft[axis] != nil {
+		f = int64(l.soft[axis][v])
+	} else {
+		f = (int64(v)*l.mul[axis] + l.add[axis]) >> 16
+	}
+
+	if l.policy != colorcube.Clamp {
+		switch {
+		case f < 0:
+			return 0, 1, int32(f)
+		case f > int64(l.Size-1)*One:
+			return l.Size - 2, l.Size - 1, int32(f - int64(l.Size-2)*One)
+		}
+	}
+
+	switch {
+	case f <= 0:
+		return 0, 1, 0
+	case f >= int64(l.Size-1)*One:
+		return l.Size - 2, l.Size - 1, One
+	}
+
+	i := int(f >> 16)
+
+	return i, i + 1, int32(f & (One - 1))
+}
+
+// Lerp will blend a and b, d is the position between them in 16.16 fixed
+// point. The result is rounded to the nearest integer.
+func Lerp(a, b, d int32) int32 {
+	return a + int32((int64(b-a)*int64(d)+One/2)>>16)
+}
+
+// Clamp will limit a colour produced by a kernel to the 16 bit range, which
+// extrapolated colours can leave.
+func Clamp(v int64) uint16 {
+	switch {
+	case v <= 0:
+		return 0
+	case v >= 0xffff:
+		return 0xffff
+	}
+
+	return uint16(v)
+}
+
+// Encode will round a normalised channel value to the nearest 16 bit value,
+// clamping it to 0..1.
+func Encode(v float64) uint16 {
+	switch {
+	case v <= 0 || math.IsNaN(v):
+		return 0
+	case v >= 1:
+		return 0xffff
+	}
+
+	return uint16(v*0xffff + 0.5)
+}
@@ -0,0 +1,96 @@
+package fixed
+
+import (
+	"math"
+	"testing"
+
+	"github.com/wayneashleyberry/lut/pkg/colorcube"
+)
+
+func TestCell(t *testing.T) {
+	tests := []struct {
+		name       string
+		size       int
+		dmin, dmax float64
+	}{
+		{"unit", 33, 0, 1},
+		{"small", 2, 0, 1},
+		{"odd", 17, 0.1, 0.9},
+		{"wide", 65, -0.5, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cube := colorcube.New(tt.size, []float64{tt.dmin, tt.dmin, tt.dmin}, []float64{tt.dmax, tt.dmax, tt.dmax})
+
+			for x := 0; x < tt.size; x++ {
+				for y := 0; y < tt.size; y++ {
+					for z := 0; z < tt.size; z++ {
+						cube.Set(x, y, z, []float64{0, 0, 0})
+					}
+				}
+			}
+
+			l := NewLattice(cube)
+
+			for v := 0; v <= 0xffff; v += 7 {
+				i0, i1, d := l.Cell(0, uint16(v))
+				w0, w1, wd := cube.Cell(0, float64(v)/0xffff)
+
+				got := float64(i0) + float64(d)/One
+				want := float64(w0) + wd
+
+				if i1 != i0+1 || math.Abs(got-want) > 2.0/One {
+					t.Fatalf("Cell(%d) = %d, %d, %d, want %d, %d, %v", v, i0, i1, d, w0, w1, wd)
+				}
+			}
+		})
+	}
+}
+
+func TestLerp(t *testing.T) {
+	tests := []struct {
+		a, b, d, want int32
+	}{
+		{0, 0xffff, 0, 0},
+		{0, 0xffff, One, 0xffff},
+		{0, 0xffff, One / 2, 0x8000},
+		{0xffff, 0, One / 4, 0xbfff},
+		{100, 200, One / 3, 133},
+	}
+
+	for _, tt := range tests {
+		if got := Lerp(tt.a, tt.b, tt.d); got != tt.want {
+			t.Errorf("Lerp(%d, %d, %d) = %d, want %d", tt.a, tt.b, tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestCellPolicy(t *testing.T) {
+	for _, policy := range []colorcube.DomainPolicy{colorcube.Extrapolate, colorcube.SoftClip} {
+		t.Run(policy.String(), func(t *testing.T) {
+			cube := colorcube.New(9, []float64{0.2, 0.2, 0.2}, []float64{0.7, 0.7, 0.7})
+			cube.DomainPolicy = policy
+
+			l := NewLattice(cube)
+
+			for v := 0; v <= 0xffff; v += 7 {
+				i0, i1, d := l.Cell(1, uint16(v))
+
+				want := (cube.Soften(1, float64(v)/0xffff) - 0.2) / 0.5 * 8
+
+				if got := float64(i0) + float64(d)/One; i1 != i0+1 || i0 < 0 || i1 > 8 || math.Abs(got-want) > 2.0/One {
+					t.Fatalf("Cell(%d) = %d, %d, %d, want %v", v, i0, i1, d, want)
+				}
+			}
+		})
+	}
+}
+
+func TestClamp(t *testing.T) {
+	for in, want := range map[int64]uint16{-5: 0, 0: 0, 0x1234: 0x1234, 0xffff: 0xffff, 0x10000: 0xffff} {
+		if got := Clamp(in); got != want {
+			t.Errorf("Clamp(%d) = %d, want %d", in, got, want)
+		}
+	}
+}
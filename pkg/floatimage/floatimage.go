@@ -0,0 +1,181 @@
+// Package floatimage implements an image type with floating point channels,
+// for high dynamic range and scene-linear images where channel values are
+// not limited to 0..1.
+package floatimage
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Color is an alpha-premultiplied colour with 32 bit floating point channels.
+// Channels are normalised so 1 is the brightest displayable value, but they
+// are not clamped and may be negative or larger than 1.
+type Color struct {
+	R, G, B, A float32
+}
+
+// RGBA implements color.Color, channels are clamped to the displayable range.
+func (c Color) RGBA() (r, g, b, a uint32) {
+	a = toUint32(c.A)
+
+	r = toUint32(c.R)
+	if r > a {
+		r = a
+	}
+
+	g = toUint32(c.G)
+	if g > a {
+		g = a
+	}
+
+	b = toUint32(c.B)
+	if b > a {
+		b = a
+	}
+
+	return r, g, b, a
+}
+
+func toUint32(v float32) uint32 {
+	switch {
+	case v <= 0:
+		return 0
+	case v >= 1:
+		return 0xffff
+	default:
+		return uint32(v*0xffff + 0.5)
+	}
+}
+
+// Model can convert any color.Color to a Color.
+var Model = color.ModelFunc(model)
+
+func model(c color.Color) color.Color {
+	if _, ok := c.(Color); ok {
+		return c
+	}
+
+	r, g, b, a := c.RGBA()
+
+	return Color{
+		R: float32(r) / 0xffff,
+		G: float32(g) / 0xffff,
+		B: float32(b) / 0xffff,
+		A: float32(a) / 0xffff,
+	}
+}
+
+// RGBAF32 is an in-memory image whose At method returns Color values.
+type RGBAF32 struct {
+	// Pix holds the image's pixels, in R, G, B, A order. The pixel at
+	// (x, y) starts at Pix[(y-Rect.Min.Y)*Stride + (x-Rect.Min.X)*4].
+	Pix []float32
+	// Stride is the Pix stride (in elements) between vertically adjacent
+	// pixels.
+	Stride int
+	// Rect is the image's bounds.
+	Rect image.Rectangle
+}
+
+var _ draw.Image = (*RGBAF32)(nil)
+
+// NewRGBAF32 returns a new RGBAF32 image with the given bounds.
+func NewRGBAF32(r image.Rectangle) *RGBAF32 {
+	return &RGBAF32{
+		Pix:    make([]float32, 4*r.Dx()*r.Dy()),
+		Stride: 4 * r.Dx(),
+		Rect:   r,
+	}
+}
+
+// ColorModel implements image.Image.
+func (p *RGBAF32) ColorModel() color.Model {
+	return Model
+}
+
+// Bounds implements image.Image.
+func (p *RGBAF32) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+// At implements image.Image.
+func (p *RGBAF32) At(x, y int) color.Color {
+	return p.RGBAF32At(x, y)
+}
+
+// RGBAF32At will return the colour of the pixel at (x, y).
+func (p *RGBAF32) RGBAF32At(x, y int) Color {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return Color{}
+	}
+
+	i := p.PixOffset(x, y)
+	s := p.Pix[i : i+4 : i+4]
+
+	return Color{s[0], s[1], s[2], s[3]}
+}
+
+// PixOffset returns the index of the first element of Pix that corresponds
+// to the pixel at (x, y).
+func (p *RGBAF32) PixOffset(x, y int) int {
+	return (y-p.Rect.Min.Y)*p.Stride + (x-p.Rect.Min.X)*4
+}
+
+// Set implements draw.Image.
+func (p *RGBAF32) Set(x, y int, c color.Color) {
+	p.SetRGBAF32(x, y, Model.Convert(c).(Color))
+}
+
+// SetRGBAF32 will set the colour of the pixel at (x, y).
+func (p *RGBAF32) SetRGBAF32(x, y int, c Color) {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return
+	}
+
+	i := p.PixOffset(x, y)
+	s := p.Pix[i : i+4 : i+4]
+	s[0] = c.R
+	s[1] = c.G
+	s[2] = c.B
+	s[3] = c.A
+}
+
+// SubImage returns an image representing the portion of the image p visible
+// through r. The returned value shares pixels with the original image.
+func (p *RGBAF32) SubImage(r image.Rectangle) image.Image {
+	r = r.Intersect(p.Rect)
+	if r.Empty() {
+		return &RGBAF32{}
+	}
+
+	i := p.PixOffset(r.Min.X, r.Min.Y)
+
+	return &RGBAF32{
+		Pix:    p.Pix[i:],
+		Stride: p.Stride,
+		Rect:   r,
+	}
+}
+
+// Opaque scans the entire image and reports whether it is fully opaque.
+func (p *RGBAF32) Opaque() bool {
+	if p.Rect.Empty() {
+		return true
+	}
+
+	for y := p.Rect.Min.Y; y < p.Rect.Max.Y; y++ {
+		i := p.PixOffset(p.Rect.Min.X, y)
+
+		for x := p.Rect.Min.X; x < p.Rect.Max.X; x++ {
+			if p.Pix[i+3] < 1 {
+				return false
+			}
+
+			i += 4
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,54 @@
+package floatimage
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func TestRGBAF32(t *testing.T) {
+	img := NewRGBAF32(image.Rect(0, 0, 4, 4))
+	img.SetRGBAF32(1, 2, Color{2, 0.5, -1, 1})
+
+	if got, want := img.RGBAF32At(1, 2), (Color{2, 0.5, -1, 1}); got != want {
+		t.Errorf("RGBAF32At() = %v, want %v", got, want)
+	}
+
+	if got, want := color.RGBA64Model.Convert(img.At(1, 2)), (color.RGBA64{0xffff, 0x8000, 0, 0xffff}); got != want {
+		t.Errorf("At() = %v, want %v", got, want)
+	}
+
+	sub := img.SubImage(image.Rect(1, 2, 3, 4)).(*RGBAF32)
+	if got, want := sub.RGBAF32At(1, 2), img.RGBAF32At(1, 2); got != want {
+		t.Errorf("SubImage().RGBAF32At() = %v, want %v", got, want)
+	}
+}
+
+func TestPFM(t *testing.T) {
+	img := NewRGBAF32(image.Rect(0, 0, 3, 2))
+	img.SetRGBAF32(0, 0, Color{0.25, 0.5, 0.75, 1})
+	img.SetRGBAF32(2, 0, Color{4, 8, 16, 1})
+	img.SetRGBAF32(1, 1, Color{-1, 0, 1, 1})
+
+	var b bytes.Buffer
+
+	if err := EncodePFM(&b, img); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := DecodePFM(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every pixel is opaque once decoded.
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 1
+	}
+
+	if !reflect.DeepEqual(got, img) {
+		t.Errorf("DecodePFM() = %v, want %v", got, img)
+	}
+}
@@ -0,0 +1,147 @@
+package floatimage
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"strconv"
+)
+
+// DecodePFM will read a Portable FloatMap image. Both colour ("PF") and
+// greyscale ("Pf") files are supported, the result is always opaque.
+func DecodePFM(r io.Reader) (*RGBAF32, error) {
+	br := bufio.NewReader(r)
+
+	magic, err := token(br)
+	if err != nil {
+		return nil, err
+	}
+
+	var channels int
+
+	switch magic {
+	case "PF":
+		channels = 3
+	case "Pf":
+		channels = 1
+	default:
+		return nil, errors.New("invalid pfm header")
+	}
+
+	var header [3]float64
+
+	for i := range header {
+		s, err := token(br)
+		if err != nil {
+			return nil, err
+		}
+
+		header[i], err = strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pfm header: %w", err)
+		}
+	}
+
+	width, height, scale := int(header[0]), int(header[1]), header[2]
+	if width <= 0 || height <= 0 || scale == 0 {
+		return nil, errors.New("invalid pfm header")
+	}
+
+	var order binary.ByteOrder = binary.BigEndian
+	if scale < 0 {
+		order = binary.LittleEndian
+	}
+
+	img := NewRGBAF32(image.Rect(0, 0, width, height))
+
+	row := make([]byte, 4*channels*width)
+
+	// Rows are stored from the bottom of the image to the top.
+	for y := height - 1; y >= 0; y-- {
+		if _, err := io.ReadFull(br, row); err != nil {
+			return nil, err
+		}
+
+		i := img.PixOffset(0, y)
+
+		for x := 0; x < width; x++ {
+			var c [3]float32
+
+			for ch := 0; ch < 3; ch++ {
+				j := 4 * (x*channels + ch%channels)
+				c[ch] = math.Float32frombits(order.Uint32(row[j:]))
+			}
+
+			img.Pix[i+0] = c[0]
+			img.Pix[i+1] = c[1]
+			img.Pix[i+2] = c[2]
+			img.Pix[i+3] = 1
+			i += 4
+		}
+	}
+
+	return img, nil
+}
+
+// EncodePFM will write any image as a little endian colour Portable FloatMap.
+// PFM files do not have an alpha channel, so colours are un-premultiplied and
+// the alpha is discarded.
+func EncodePFM(w io.Writer, img image.Image) error {
+	bw := bufio.NewWriter(w)
+
+	b := img.Bounds()
+
+	if _, err := fmt.Fprintf(bw, "PF\n%d %d\n-1.0\n", b.Dx(), b.Dy()); err != nil {
+		return err
+	}
+
+	row := make([]byte, 12*b.Dx())
+
+	for y := b.Max.Y - 1; y >= b.Min.Y; y-- {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := Model.Convert(img.At(x, y)).(Color)
+
+			r, g, bl := c.R, c.G, c.B
+			if c.A > 0 && c.A != 1 {
+				r, g, bl = r/c.A, g/c.A, bl/c.A
+			}
+
+			j := 12 * (x - b.Min.X)
+			binary.LittleEndian.PutUint32(row[j:], math.Float32bits(r))
+			binary.LittleEndian.PutUint32(row[j+4:], math.Float32bits(g))
+			binary.LittleEndian.PutUint32(row[j+8:], math.Float32bits(bl))
+		}
+
+		if _, err := bw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// token will read a single whitespace delimited header field, along with the
+// whitespace character which follows it.
+func token(r *bufio.Reader) (string, error) {
+	var b []byte
+
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			if len(b) > 0 {
+				return string(b), nil
+			}
+		default:
+			b = append(b, c)
+		}
+	}
+}
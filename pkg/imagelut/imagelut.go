@@ -4,32 +4,35 @@ import (
 	"errors"
 	"image"
 	"image/color"
+	"image/draw"
 	"math"
 
 	"github.com/wayneashleyberry/lut/pkg/colorcube"
-	"github.com/wayneashleyberry/lut/pkg/parallel"
+	"github.com/wayneashleyberry/lut/pkg/lut"
+	"github.com/wayneashleyberry/lut/pkg/trilinear"
 )
 
-// FromColorCube will create an image from a color cube.
+// FromColorCube will create an image from a color cube. The slices of the
+// cube are stored as tiles, eight to a row, so the image has as many rows of
+// tiles as it needs. Colours outside of 0..1 are clamped.
 func FromColorCube(cube colorcube.Cube) image.Image {
 	out := image.NewNRGBA(image.Rectangle{
 		image.Point{0, 0},
-		image.Point{cube.Size * 8, cube.Size * 8},
+		image.Point{cube.Size * 8, (cube.Size + 7) / 8 * cube.Size},
 	})
 
+	// Each row of a tile holds consecutive points of the cube, as red changes
+	// fastest in both.
 	for z := 0; z < cube.Size; z++ {
-		for x := 0; x < cube.Size; x++ {
-			for y := 0; y < cube.Size; y++ {
-				imgx := (z % 8 * cube.Size) + x
-				imgy := (z / 8 * cube.Size) + y
-				rgb := cube.Get(x, y, z)
-
-				out.SetNRGBA(imgx, imgy, color.NRGBA{
-					R: uint8(rgb[0] * 0xff),
-					G: uint8(rgb[1] * 0xff),
-					B: uint8(rgb[2] * 0xff),
-					A: 0xff,
-				})
+		for y := 0; y < cube.Size; y++ {
+			src := cube.Data[3*cube.Index(0, y, z):]
+			dst := out.Pix[out.PixOffset(z%8*cube.Size, z/8*cube.Size+y):]
+
+			for x := 0; x < cube.Size; x++ {
+				dst[4*x+0] = encode(src[3*x+0])
+				dst[4*x+1] = encode(src[3*x+1])
+				dst[4*x+2] = encode(src[3*x+2])
+				dst[4*x+3] = 0xff
 			}
 		}
 	}
@@ -37,7 +40,22 @@ func FromColorCube(cube colorcube.Cube) image.Image {
 	return out
 }
 
-// Parse implementation.
+// encode will round a channel to the nearest 8 bit value, clamping it to
+// 0..1.
+func encode(v float64) uint8 {
+	switch {
+	case v <= 0 || math.IsNaN(v):
+		return 0
+	case v >= 1:
+		return 0xff
+	}
+
+	return uint8(v*0xff + 0.5)
+}
+
+// Parse will read a 512x512 lookup table image into a cube with 64 points
+// per axis, evenly spaced so the lattice points 0..63 map to the channel
+// values 0..255.
 func Parse(src image.Image) (colorcube.Cube, error) {
 	// hardcoded defaults
 	size := 64
@@ -51,8 +69,7 @@ func Parse(src image.Image) (colorcube.Cube, error) {
 		return cube, errors.New("invalid image size")
 	}
 
-	space := &image.NRGBA{}
-	model := space.ColorModel()
+	model := color.NRGBA64Model
 
 	for z := 0; z < size; z++ {
 		for x := 0; x < size; x++ {
@@ -60,13 +77,12 @@ func Parse(src image.Image) (colorcube.Cube, error) {
 				imgx := (z % 8 * 64) + x
 				imgy := (z / 8 * 64) + y
 				px := src.At(imgx, imgy)
-				c := model.Convert(px).(color.NRGBA)
+				c := model.Convert(px).(color.NRGBA64)
+				rgb := cube.Get(x, y, z)
 
-				cube.Set(x, y, z, []float64{
-					float64(c.R) / 0xff,
-					float64(c.G) / 0xff,
-					float64(c.B) / 0xff,
-				})
+				rgb[0] = float64(c.R) / 0xffff
+				rgb[1] = float64(c.G) / 0xffff
+				rgb[2] = float64(c.B) / 0xffff
 			}
 		}
 	}
@@ -75,47 +91,52 @@ func Parse(src image.Image) (colorcube.Cube, error) {
 }
 
 // Apply colour transformations to an image from the provided lookup table.
-func Apply(src, effect image.Image, intensity float64) (image.Image, error) {
-	if intensity < 0 || intensity > 1 {
-		return src, errors.New("intensity must be between 0 and 1")
-	}
-
-	bounds := src.Bounds()
-
-	out := image.NewNRGBA(image.Rectangle{
-		image.Point{0, 0},
-		image.Point{bounds.Max.X, bounds.Max.Y},
-	})
+// The lookup table can be stored in any image type, and is sampled with
+// trilinear interpolation. Earlier versions used the nearest lattice point,
+// which posterizes gradients, see Interpolate to pick the interpolator.
+func Apply(src, effect image.Image, intensity float64, opts ...lut.Option) (image.Image, error) {
+	return Interpolate(src, effect, intensity, trilinear.Lookup, opts...)
+}
 
-	space := &image.NRGBA{}
-	model := space.ColorModel()
+// ApplyTo will apply colour transformations to the rectangle r of dst from
+// the provided lookup table, reading from src starting at sp. See Apply for
+// the lookup table and lut.ApplyTo for details.
+func ApplyTo(dst draw.Image, r image.Rectangle, src image.Image, sp image.Point, effect image.Image, intensity float64, opts ...lut.Option) error {
+	return InterpolateTo(dst, r, src, sp, effect, intensity, trilinear.Lookup, opts...)
+}
 
-	width, height := bounds.Dx(), bounds.Dy()
-	parallel.Line(height, func(start, end int) {
-		for y := start; y < end; y++ {
-			for x := 0; x < width; x++ {
-				// not all images use the same colour space, so ensure we convert
-				// them all to nrgba to be consistent with our output
-				px := src.At(x, y)
-				c := model.Convert(px).(color.NRGBA)
+// Interpolate will apply colour transformations to an image from the provided
+// lookup table like Apply, sampling it with the given interpolator (nearest
+// neighbour if interp is nil).
+func Interpolate(src, effect image.Image, intensity float64, interp colorcube.Interpolator, opts ...lut.Option) (image.Image, error) {
+	cube, err := Parse(effect)
+	if err != nil {
+		return src, err
+	}
 
-				// find the location of the pixel in our lookup table
-				lutx := int((c.B/4%8)*64 + c.R/4)
-				luty := int(math.Floor(float64(c.B/4)/8)*64 + float64(c.G/4))
+	if interp == nil {
+		interp = colorcube.Nearest
+	}
 
-				lut := effect.At(lutx, luty).(color.RGBA)
+	return lut.Apply(src, func(r, g, b float64) (float64, float64, float64) {
+		return interp(cube, r, g, b)
+	}, intensity, opts...)
+}
 
-				// create our output colour, adjusted according to the intensity
-				o := color.NRGBA{}
-				o.R = uint8(float64(c.R)*(1-intensity) + float64(lut.R)*intensity)
-				o.G = uint8(float64(c.G)*(1-intensity) + float64(lut.G)*intensity)
-				o.B = uint8(float64(c.B)*(1-intensity) + float64(lut.B)*intensity)
-				o.A = c.A
+// InterpolateTo will apply colour transformations to the rectangle r of dst
+// from the provided lookup table like ApplyTo, sampling it with the given
+// interpolator (nearest neighbour if interp is nil).
+func InterpolateTo(dst draw.Image, r image.Rectangle, src image.Image, sp image.Point, effect image.Image, intensity float64, interp colorcube.Interpolator, opts ...lut.Option) error {
+	cube, err := Parse(effect)
+	if err != nil {
+		return err
+	}
 
-				out.Set(x, y, o)
-			}
-		}
-	})
+	if interp == nil {
+		interp = colorcube.Nearest
+	}
 
-	return out, nil
+	return lut.ApplyTo(dst, r, src, sp, func(r, g, b float64) (float64, float64, float64) {
+		return interp(cube, r, g, b)
+	}, intensity, opts...)
 }
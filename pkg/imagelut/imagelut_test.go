@@ -0,0 +1,148 @@
+package imagelut
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/wayneashleyberry/lut/pkg/colorcube"
+	"github.com/wayneashleyberry/lut/pkg/interptest"
+	"github.com/wayneashleyberry/lut/pkg/tetrahedral"
+	"github.com/wayneashleyberry/lut/pkg/trilinear"
+	"github.com/wayneashleyberry/lut/pkg/util"
+)
+
+func TestApply(t *testing.T) {
+	neutral, err := util.ReadImage("../../testdata/filters/Neutral.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The same lookup table stored in other image types.
+	nrgba := image.NewNRGBA(neutral.Bounds())
+	draw.Draw(nrgba, nrgba.Bounds(), neutral, image.Point{}, draw.Src)
+
+	rgba64 := image.NewRGBA64(neutral.Bounds())
+	draw.Draw(rgba64, rgba64.Bounds(), neutral, image.Point{}, draw.Src)
+
+	src := interptest.Gradient(256, 64)
+
+	tests := []struct {
+		name   string
+		effect image.Image
+		interp colorcube.Interpolator
+	}{
+		{"nearest", neutral, nil},
+		{"trilinear", neutral, trilinear.Lookup},
+		{"tetrahedral", neutral, tetrahedral.Lookup},
+		{"nrgba", nrgba, trilinear.Lookup},
+		{"rgba64", rgba64, trilinear.Lookup},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := Interpolate(src, tt.effect, 1, tt.interp)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			max := 1
+			if tt.interp == nil {
+				// Nearest neighbour is off by up to half a lattice step.
+				max = 3
+			}
+
+			bounds := src.Bounds()
+
+			for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+				for x := bounds.Min.X; x < bounds.Max.X; x++ {
+					got := out.At(x, y).(color.NRGBA)
+					want := src.At(x, y).(color.NRGBA)
+
+					if diff(got.R, want.R) > max || diff(got.G, want.G) > max || diff(got.B, want.B) > max {
+						t.Fatalf("Apply() at (%d, %d) = %v, want %v", x, y, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestApplyTrilinear(t *testing.T) {
+	neutral, err := util.ReadImage("../../testdata/filters/Neutral.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := interptest.Gradient(256, 64)
+
+	got, err := Apply(src, neutral, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := Interpolate(src, neutral, 1, trilinear.Lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got.(*image.NRGBA).Pix, want.(*image.NRGBA).Pix) {
+		t.Error("Apply() should sample the lookup table with trilinear interpolation")
+	}
+}
+
+func diff(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+
+	return int(b - a)
+}
+
+func TestFromColorCube(t *testing.T) {
+	neutral, err := util.ReadImage("../../testdata/filters/Neutral.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cube, err := Parse(neutral)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img := FromColorCube(cube)
+
+	bounds := neutral.Bounds()
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if got, want := color.NRGBAModel.Convert(img.At(x, y)), color.NRGBAModel.Convert(neutral.At(x, y)); got != want {
+				t.Fatalf("FromColorCube() at (%d, %d) = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestFromColorCubeSize(t *testing.T) {
+	for _, size := range []int{2, 9, 64, 65} {
+		cube := colorcube.New(size, []float64{0, 0, 0}, []float64{1, 1, 1})
+
+		// Values outside of 0..1, as baked transforms can produce.
+		cube.Set(size-1, size-1, size-1, []float64{1.5, -0.5, 0.5})
+
+		img := FromColorCube(cube)
+
+		if got, want := img.Bounds().Size(), image.Pt(size*8, (size+7)/8*size); got != want {
+			t.Errorf("FromColorCube(%d) size = %v, want %v", size, got, want)
+		}
+
+		z := size - 1
+		got := img.At(z%8*size+size-1, z/8*size+size-1)
+
+		if want := (color.NRGBA{0xff, 0, 0x80, 0xff}); got != want {
+			t.Errorf("FromColorCube(%d) last point = %v, want %v", size, got, want)
+		}
+	}
+}
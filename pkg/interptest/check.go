@@ -0,0 +1,50 @@
+package interptest
+
+import (
+	"testing"
+
+	"github.com/wayneashleyberry/lut/pkg/colorcube"
+	"github.com/wayneashleyberry/lut/pkg/lut"
+)
+
+// Check will run the checks every interpolator must pass on synthetic cubes:
+// linear functions are reproduced exactly, a gamma curve is at most one code
+// value less accurate than ref, and linear functions are extrapolated exactly
+// beyond a reduced domain. A nil ref skips the comparison.
+func Check(t *testing.T, interp, ref colorcube.Interpolator) {
+	t.Helper()
+
+	tests := []struct {
+		name  string
+		fn    lut.Func
+		exact bool
+	}{
+		{"identity", Identity, true},
+		{"gain", Gain(0.8, 1, 0.5), true},
+		{"matrix", CrossTalk, true},
+		{"gamma", Gamma(2.2), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := Measure(interp, 17, tt.fn, 23)
+			if tt.exact && res.Max > 1e-9 {
+				t.Errorf("max error = %v, mean error = %v", res.Max, res.Mean)
+			}
+
+			if ref == nil {
+				return
+			}
+
+			if want := Measure(ref, 17, tt.fn, 23); res.CodeValues() > want.CodeValues()+1 {
+				t.Errorf("max error = %v, reference %v", res.Max, want.Max)
+			}
+		})
+	}
+
+	t.Run("extrapolate", func(t *testing.T) {
+		if res := Extrapolation(interp, 9, CrossTalk, 19); res.Max > 1e-9 {
+			t.Errorf("max extrapolation error = %v", res.Max)
+		}
+	})
+}
@@ -0,0 +1,137 @@
+package interptest
+
+import (
+	"image"
+	"os"
+	"testing"
+
+	"github.com/wayneashleyberry/lut/pkg/colorcube"
+	"github.com/wayneashleyberry/lut/pkg/cubelut"
+	"github.com/wayneashleyberry/lut/pkg/lut"
+	"github.com/wayneashleyberry/lut/pkg/util"
+)
+
+// Filter will read a cube from the testdata/filters directory, relative to a
+// package in pkg.
+func Filter(t *testing.T, name string) colorcube.Cube {
+	t.Helper()
+
+	f, err := os.Open("../../testdata/filters/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	cf, err := cubelut.Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cf.Cube()
+}
+
+// photo is a crop of an image from the testdata/images directory.
+type photo struct {
+	name string
+	img  image.Image
+}
+
+// images will read crops of an opaque and a transparent photo, which are
+// large enough to cover most of a cube.
+func images(t *testing.T) []photo {
+	t.Helper()
+
+	crops := []struct {
+		name string
+		crop image.Rectangle
+	}{
+		{"sample.jpg", image.Rect(0, 0, 1500, 1000)},
+		{"transparent.png", image.Rect(900, 0, 1400, 400)},
+	}
+
+	out := make([]photo, len(crops))
+
+	for i, c := range crops {
+		img, err := util.ReadImage("../../testdata/images/" + c.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out[i] = photo{c.name, img.(interface {
+			SubImage(image.Rectangle) image.Image
+		}).SubImage(c.crop)}
+	}
+
+	return out
+}
+
+// CheckFixed will grade the testdata images with interp and with the integer
+// kernel k, which must be built from the same cube, and fail t when they
+// differ by more than one code value. Straight and premultiplied alpha are
+// checked at full and half intensity, and k must not allocate.
+func CheckFixed(t *testing.T, cube colorcube.Cube, interp colorcube.Interpolator, k lut.Fixed) {
+	t.Helper()
+
+	fn := func(r, g, b float64) (float64, float64, float64) {
+		return interp(cube, r, g, b)
+	}
+
+	for _, s := range images(t) {
+		for _, alpha := range []lut.AlphaMode{lut.Straight, lut.Premultiplied} {
+			for _, intensity := range []float64{1, 0.5} {
+				want, err := lut.Apply(s.img, fn, intensity, lut.WithAlpha(alpha))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				got, err := lut.Apply(s.img, fn, intensity, lut.WithAlpha(alpha), lut.WithFixed(k))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if d := Diff(got, want); d > 1 {
+					t.Errorf("%s at %v with alpha %d differs by %d code values with fixed point", s.name, intensity, alpha, d)
+				}
+			}
+		}
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		k.Map16(0x1234, 0x8000, 0xfedc)
+	})
+
+	if allocs != 0 {
+		t.Errorf("Map16() allocations = %v, want 0", allocs)
+	}
+}
+
+// CheckImages will grade the testdata images with interp and with ref, and
+// fail t when they differ by more than max code values. The difference is
+// logged, so running the tests verbosely compares interpolators on real
+// photos.
+func CheckImages(t *testing.T, cube colorcube.Cube, interp, ref colorcube.Interpolator, max int) {
+	t.Helper()
+
+	for _, p := range images(t) {
+		got, err := lut.Apply(p.img, func(r, g, b float64) (float64, float64, float64) {
+			return interp(cube, r, g, b)
+		}, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want, err := lut.Apply(p.img, func(r, g, b float64) (float64, float64, float64) {
+			return ref(cube, r, g, b)
+		}, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		d := Diff(got, want)
+		t.Logf("%s differs by %d code values", p.name, d)
+
+		if d > max {
+			t.Errorf("%s differs by %d code values, want at most %d", p.name, d, max)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+// Package interptest provides utilities for measuring the accuracy of colour
+// cube interpolation. A cube is sampled from a known function and the
+// interpolated values in between the lattice points are compared to the
+// function itself.
+package interptest
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/wayneashleyberry/lut/pkg/colorcube"
+	"github.com/wayneashleyberry/lut/pkg/lut"
+)
+
+// Result contains the absolute error of an interpolator, measured in
+// normalised channel values (0..1).
+type Result struct {
+	Max  float64
+	Mean float64
+}
+
+// CodeValues will return the maximum error expressed in 8 bit code values.
+func (r Result) CodeValues() float64 {
+	return r.Max * 0xff
+}
+
+// Identity leaves colours untouched.
+func Identity(r, g, b float64) (float64, float64, float64) {
+	return r, g, b
+}
+
+// Gain will create a function which multiplies each channel separately.
+func Gain(kr, kg, kb float64) lut.Func {
+	return func(r, g, b float64) (float64, float64, float64) {
+		return r * kr, g * kg, b * kb
+	}
+}
+
+// Matrix will create a function which multiplies colours by a row major 3x3
+// matrix.
+func Matrix(m [9]float64) lut.Func {
+	return func(r, g, b float64) (float64, float64, float64) {
+		return m[0]*r + m[1]*g + m[2]*b,
+			m[3]*r + m[4]*g + m[5]*b,
+			m[6]*r + m[7]*g + m[8]*b
+	}
+}
+
+// CrossTalk mixes a little of every channel into the others, like a typical
+// colour matrix.
+var CrossTalk = Matrix([9]float64{0.6, 0.3, 0.1, 0.2, 0.7, 0.1, 0.1, 0.1, 0.8})
+
+// Gamma will create a function which raises each channel to the power of p.
+func Gamma(p float64) lut.Func {
+	return func(r, g, b float64) (float64, float64, float64) {
+		return math.Pow(r, p), math.Pow(g, p), math.Pow(b, p)
+	}
+}
+
+// Cube will create a cube of the given size by sampling fn at every lattice
+// point.
+func Cube(size int, fn lut.Func) colorcube.Cube {
+	cube := colorcube.New(size, []float64{0, 0, 0}, []float64{1, 1, 1})
+
+	k := float64(size - 1)
+
+	for x := 0; x < size; x++ {
+		for y := 0; y < size; y++ {
+			for z := 0; z < size; z++ {
+				r, g, b := fn(float64(x)/k, float64(y)/k, float64(z)/k)
+				cube.Set(x, y, z, []float64{r, g, b})
+			}
+		}
+	}
+
+	return cube
+}
+
+// Measure will sample fn into a cube of the given size and compare interp
+// against fn on a regular grid of steps points per axis. Choosing a number of
+// steps which doesn't line up with the lattice ensures most samples fall in
+// between lattice points.
+func Measure(interp colorcube.Interpolator, size int, fn lut.Func, steps int) Result {
+	return measure(interp, Cube(size, fn), fn, steps, 0, 1)
+}
+
+// Extrapolation will sample fn into a cube of the given size with a domain of
+// 0.25..0.75 and the colorcube.Extrapolate policy, and compare interp against
+// fn on a regular grid from -0.25 to 1.25, mostly outside of the domain.
+// Linear functions should be extrapolated exactly.
+func Extrapolation(interp colorcube.Interpolator, size int, fn lut.Func, steps int) Result {
+	cube := colorcube.New(size, []float64{0.25, 0.25, 0.25}, []float64{0.75, 0.75, 0.75})
+	cube.DomainPolicy = colorcube.Extrapolate
+
+	k := float64(size - 1)
+
+	for x := 0; x < size; x++ {
+		for y := 0; y < size; y++ {
+			for z := 0; z < size; z++ {
+				r, g, b := fn(0.25+float64(x)/k/2, 0.25+float64(y)/k/2, 0.25+float64(z)/k/2)
+				cube.Set(x, y, z, []float64{r, g, b})
+			}
+		}
+	}
+
+	return measure(interp, cube, fn, steps, -0.25, 1.25)
+}
+
+// measure will compare interp against fn on a regular grid from lo to hi.
+func measure(interp colorcube.Interpolator, cube colorcube.Cube, fn lut.Func, steps int, lo, hi float64) Result {
+	var res Result
+
+	var sum float64
+
+	n := 0
+
+	for i := 0; i < steps; i++ {
+		for j := 0; j < steps; j++ {
+			for k := 0; k < steps; k++ {
+				r := lo + (hi-lo)*sample(i, steps)
+				g := lo + (hi-lo)*sample(j, steps)
+				b := lo + (hi-lo)*sample(k, steps)
+
+				wr, wg, wb := fn(r, g, b)
+				gr, gg, gb := interp(cube, r, g, b)
+
+				for _, e := range []float64{math.Abs(gr - wr), math.Abs(gg - wg), math.Abs(gb - wb)} {
+					sum += e
+					n++
+
+					if e > res.Max {
+						res.Max = e
+					}
+				}
+			}
+		}
+	}
+
+	if n > 0 {
+		res.Mean = sum / float64(n)
+	}
+
+	return res
+}
+
+func sample(i, steps int) float64 {
+	if steps < 2 {
+		return 0.5
+	}
+
+	return float64(i) / float64(steps-1)
+}
+
+// Gradient will create an opaque image of the given size in which red rises
+// and green falls from left to right, while blue rises from top to bottom.
+func Gradient(width, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			v := uint8(x * 256 / width)
+			img.SetNRGBA(x, y, color.NRGBA{v, 255 - v, uint8(y * 256 / height), 0xff})
+		}
+	}
+
+	return img
+}
+
+// Diff will return the largest difference between the straight 8 bit channels
+// of two images with the same bounds, which is used to compare alternative
+// implementations of an interpolator.
+func Diff(a, b image.Image) int {
+	max := 0
+
+	bounds := a.Bounds()
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			ca := color.NRGBAModel.Convert(a.At(x, y)).(color.NRGBA)
+			cb := color.NRGBAModel.Convert(b.At(x, y)).(color.NRGBA)
+
+			for _, d := range []int{
+				int(ca.R) - int(cb.R),
+				int(ca.G) - int(cb.G),
+				int(ca.B) - int(cb.B),
+				int(ca.A) - int(cb.A),
+			} {
+				if d < 0 {
+					d = -d
+				}
+
+				if d > max {
+					max = d
+				}
+			}
+		}
+	}
+
+	return max
+}
@@ -0,0 +1,144 @@
+package lut
+
+import (
+	"image"
+	"image/draw"
+	"image/png"
+	"os"
+	"testing"
+)
+
+// transparent will return a crop of the transparent test image, which has
+// fully transparent and partially transparent areas, in both straight and
+// premultiplied form.
+func transparent(t *testing.T) (*image.NRGBA, *image.RGBA) {
+	t.Helper()
+
+	f, err := os.Open("../../testdata/images/transparent.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := image.Rect(900, 0, 1100, 100)
+
+	// Copy the straight pixels directly, drawing would premultiply them.
+	straight := img.(*image.NRGBA)
+
+	nrgba := image.NewNRGBA(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		copy(nrgba.Pix[nrgba.PixOffset(r.Min.X, y):nrgba.PixOffset(r.Max.X, y)], straight.Pix[straight.PixOffset(r.Min.X, y):])
+	}
+
+	rgba := image.NewRGBA(r)
+	draw.Draw(rgba, r, img, r.Min, draw.Src)
+
+	return nrgba, rgba
+}
+
+func TestApplyAlpha(t *testing.T) {
+	nrgba, rgba := transparent(t)
+
+	for _, mode := range []AlphaMode{Straight, Premultiplied, SkipTransparent} {
+		for _, src := range []image.Image{nrgba, rgba} {
+			out, err := Apply(src, identity, 1, WithAlpha(mode))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			switch src := src.(type) {
+			case *image.NRGBA:
+				got, ok := out.(*image.NRGBA)
+				if !ok {
+					t.Fatalf("Apply() returned %T, want *image.NRGBA", out)
+				}
+
+				// Premultiplying is lossy for straight sources.
+				if mode != Premultiplied && string(got.Pix) != string(src.Pix) {
+					t.Errorf("Apply(%T, %d) did not round trip", src, mode)
+				}
+			case *image.RGBA:
+				got, ok := out.(*image.RGBA)
+				if !ok {
+					t.Fatalf("Apply() returned %T, want *image.RGBA", out)
+				}
+
+				if string(got.Pix) != string(src.Pix) {
+					t.Errorf("Apply(%T, %d) did not round trip", src, mode)
+				}
+			}
+		}
+	}
+}
+
+func TestApplyPremultiplied(t *testing.T) {
+	_, src := transparent(t)
+
+	half := func(r, g, b float64) (float64, float64, float64) {
+		return r / 2, g / 2, b / 2
+	}
+
+	brighten := func(r, g, b float64) (float64, float64, float64) {
+		return r + 0.5, g + 0.5, b + 0.5
+	}
+
+	out, err := Apply(src, half, 1, WithAlpha(Premultiplied))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img := out.(*image.RGBA)
+
+	for i, v := range src.Pix {
+		want := v
+		if i%4 != 3 {
+			want = uint8((int(v) + 1) / 2)
+		}
+
+		if d := int(img.Pix[i]) - int(want); d < -1 || d > 1 {
+			t.Fatalf("Apply() Pix[%d] = %d, want %d", i, img.Pix[i], want)
+		}
+	}
+
+	// Fully transparent pixels are only left alone when skipped.
+	nrgba, _ := transparent(t)
+
+	straight, err := Apply(nrgba, brighten, 1, WithAlpha(Straight))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	skipped, err := Apply(nrgba, brighten, 1, WithAlpha(SkipTransparent))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cleared := 0
+
+	for i := 3; i < len(nrgba.Pix); i += 4 {
+		if nrgba.Pix[i] != 0 {
+			continue
+		}
+
+		cleared++
+
+		want := string(nrgba.Pix[i-3 : i+1])
+
+		if got := string(skipped.(*image.NRGBA).Pix[i-3 : i+1]); got != want {
+			t.Fatalf("Apply() with SkipTransparent changed a transparent pixel from %v to %v", []byte(want), []byte(got))
+		}
+
+		if got := string(straight.(*image.NRGBA).Pix[i-3 : i+1]); got == want {
+			t.Fatalf("Apply() with Straight did not grade a transparent pixel %v", []byte(want))
+		}
+	}
+
+	if cleared == 0 {
+		t.Fatal("test image has no transparent pixels")
+	}
+}
@@ -0,0 +1,114 @@
+package lut
+
+import "math"
+
+// BlendMode selects how the graded colour of a pixel is combined with its
+// original colour, before the two are mixed according to the intensity. The
+// original colour is the base layer and the graded colour is the blend layer,
+// as in an image editor.
+type BlendMode int
+
+// Supported blend modes.
+const (
+	// Normal replaces the original colour with the graded colour, this is
+	// the default.
+	Normal BlendMode = iota
+	// Luminosity keeps the hue and saturation of the original colour and
+	// takes the luma of the graded colour.
+	Luminosity
+	// Color keeps the luma of the original colour and takes the hue and
+	// saturation of the graded colour, which preserves brightness.
+	Color
+	// Overlay multiplies the dark parts and screens the light parts of the
+	// original colour.
+	Overlay
+	// SoftLight darkens or lightens the original colour depending on the
+	// graded colour, like a diffused spotlight.
+	SoftLight
+	// Multiply multiplies both colours, which always darkens.
+	Multiply
+	// Screen inverts, multiplies and inverts both colours, which always
+	// lightens.
+	Screen
+)
+
+// WithBlend will set how graded colours are combined with the original
+// colours. Modes other than Normal always use the Func passed to Apply, not
+// an integer kernel.
+func WithBlend(mode BlendMode) Option {
+	return func(o *options) {
+		o.blend = mode
+	}
+}
+
+// blend will combine the original colour (r, g, b) with the graded colour
+// (lr, lg, lb).
+func blend(mode BlendMode, r, g, b, lr, lg, lb float64) (float64, float64, float64) {
+	switch mode {
+	case Luminosity:
+		return setLuma(r, g, b, luma(lr, lg, lb))
+	case Color:
+		return setLuma(lr, lg, lb, luma(r, g, b))
+	case Overlay:
+		return overlay(r, lr), overlay(g, lg), overlay(b, lb)
+	case SoftLight:
+		return softLight(r, lr), softLight(g, lg), softLight(b, lb)
+	case Multiply:
+		return r * lr, g * lg, b * lb
+	case Screen:
+		return screen(r, lr), screen(g, lg), screen(b, lb)
+	default:
+		return lr, lg, lb
+	}
+}
+
+func screen(s, l float64) float64 {
+	return s + l - s*l
+}
+
+func overlay(s, l float64) float64 {
+	if s <= 0.5 {
+		return 2 * s * l
+	}
+
+	return screen(2*s-1, l)
+}
+
+// softLight follows the W3C compositing specification, which matches image
+// editors without the discontinuity of older formulas.
+func softLight(s, l float64) float64 {
+	if l <= 0.5 {
+		return s - (1-2*l)*s*(1-s)
+	}
+
+	var d float64
+
+	if s <= 0.25 {
+		d = ((16*s-12)*s + 4) * s
+	} else {
+		d = math.Sqrt(math.Max(s, 0))
+	}
+
+	return s + (2*l-1)*(d-s)
+}
+
+// setLuma will shift a colour to the luma y, keeping its hue. Channels which
+// end up outside of 0..1 are pulled back towards the luma, as long as it is
+// inside of that range, so floating point colours brighter than white are
+// kept.
+func setLuma(r, g, b, y float64) (float64, float64, float64) {
+	d := y - luma(r, g, b)
+	r, g, b = r+d, g+d, b+d
+
+	if n := math.Min(r, math.Min(g, b)); n < 0 && y >= 0 {
+		k := y / (y - n)
+		r, g, b = y+(r-y)*k, y+(g-y)*k, y+(b-y)*k
+	}
+
+	if x := math.Max(r, math.Max(g, b)); x > 1 && y <= 1 {
+		k := (1 - y) / (x - y)
+		r, g, b = y+(r-y)*k, y+(g-y)*k, y+(b-y)*k
+	}
+
+	return r, g, b
+}
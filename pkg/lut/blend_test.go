@@ -0,0 +1,108 @@
+package lut
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestBlend(t *testing.T) {
+	tests := []struct {
+		mode BlendMode
+		s, l float64
+		want float64
+	}{
+		{Normal, 0.2, 0.6, 0.6},
+		{Multiply, 0.5, 0.6, 0.3},
+		{Screen, 0.5, 0.6, 0.8},
+		{Overlay, 0.25, 0.6, 0.3},
+		{Overlay, 0.75, 0.6, 0.8},
+		{SoftLight, 0.5, 0.5, 0.5},
+		{SoftLight, 0.5, 0.25, 0.375},
+		{SoftLight, 0.25, 1, 0.5},
+		{SoftLight, 0.1, 0.75, 0.1 + 0.5*(((1.6-12)*0.1+4)*0.1-0.1)},
+	}
+
+	for _, tt := range tests {
+		r, g, b := blend(tt.mode, tt.s, tt.s, tt.s, tt.l, tt.l, tt.l)
+
+		for _, got := range []float64{r, g, b} {
+			if math.Abs(got-tt.want) > 1e-12 {
+				t.Errorf("blend(%d, %v, %v) = %v, want %v", tt.mode, tt.s, tt.l, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestBlendLuma(t *testing.T) {
+	colours := [][3]float64{
+		{0.2, 0.4, 0.9},
+		{0.9, 0.1, 0.1},
+		{0, 0, 0},
+		{1, 1, 1},
+		{0.5, 0.5, 0.5},
+		{0.05, 0.95, 0.3},
+	}
+
+	for _, s := range colours {
+		for _, l := range colours {
+			r, g, b := blend(Luminosity, s[0], s[1], s[2], l[0], l[1], l[2])
+			if got, want := luma(r, g, b), luma(l[0], l[1], l[2]); math.Abs(got-want) > 1e-9 {
+				t.Errorf("Luminosity luma of %v over %v = %v, want %v", l, s, got, want)
+			}
+
+			r, g, b = blend(Color, s[0], s[1], s[2], l[0], l[1], l[2])
+			if got, want := luma(r, g, b), luma(s[0], s[1], s[2]); math.Abs(got-want) > 1e-9 {
+				t.Errorf("Color luma of %v over %v = %v, want %v", l, s, got, want)
+			}
+
+			for _, v := range []float64{r, g, b} {
+				if v < -1e-12 || v > 1+1e-12 {
+					t.Errorf("Color of %v over %v = %v, %v, %v, want 0..1", l, s, r, g, b)
+				}
+			}
+		}
+	}
+
+	// Grey has no hue, so the luminosity of a grey grade is grey.
+	if r, g, b := blend(Color, 0.2, 0.4, 0.9, 0.5, 0.5, 0.5); math.Abs(r-g) > 1e-12 || math.Abs(g-b) > 1e-12 {
+		t.Errorf("Color of grey = %v, %v, %v, want grey", r, g, b)
+	}
+}
+
+func TestApplyBlend(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for i := range src.Pix {
+		src.Pix[i] = uint8(i * 15)
+	}
+
+	// Multiplying a colour by its inverse at half intensity, integer
+	// kernels are ignored for blend modes other than Normal.
+	out, err := Apply(src, invert, 0.5, WithBlend(Multiply), WithFixed(invert16{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, v := range src.Pix {
+		if i%4 == 3 {
+			continue
+		}
+
+		s := float64(v) / 0xff
+		want := uint8(quantize(s*0.5+s*(1-s)*0.5, 0xff))
+
+		if got := out.(*image.NRGBA).Pix[i]; got != want {
+			t.Fatalf("Apply() channel %d = %d, want %d", i, got, want)
+		}
+	}
+
+	if _, err := Apply(src, invert, 1, WithBlend(Screen+1)); err == nil {
+		t.Error("Apply() expected an error for an invalid blend mode")
+	}
+
+	m := NewModel(Func(invert), WithBlend(Screen))
+	if got, want := m.Convert(color.NRGBA64{0x8000, 0, 0xffff, 0xffff}), (color.NRGBA64{0xbfff, 0xffff, 0xffff, 0xffff}); got != want {
+		t.Errorf("NewModel() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,81 @@
+package lut
+
+import "math"
+
+// Clip selects how graded colours outside of 0..1 are brought back into
+// range. Intensities below 0 or above 1 extrapolate the mix between the
+// original and graded colour, which easily leaves the displayable range.
+type Clip int
+
+// Supported clip policies.
+const (
+	// HardClip clamps every channel to 0..1 when it is rounded, this is the
+	// default. Floating point images are not clamped.
+	HardClip Clip = iota
+	// SoftClip compresses channels near the ends of the range onto a smooth
+	// shoulder, so detail beyond 0..1 fades out instead of flattening. The
+	// shoulder reaches 0 and 1 at the furthest values the intensity can mix,
+	// so intensities within 0..1 leave colours untouched, and channels
+	// between 0.1 and 0.9 are never changed.
+	SoftClip
+	// PreserveHue pulls every channel of an out of range colour towards its
+	// luma by the same factor, which keeps its hue but not its saturation.
+	PreserveHue
+)
+
+// knee is the distance from either end of the range at which SoftClip starts
+// to compress channels.
+const knee = 0.1
+
+// WithClip will set how graded colours outside of 0..1 are brought back into
+// range, after they are mixed with the original colour.
+func WithClip(c Clip) Option {
+	return func(o *options) {
+		o.clip = c
+	}
+}
+
+// clipColor will apply a clip policy to a straight colour which was mixed
+// with the given intensity.
+func clipColor(c Clip, intensity float64, r, g, b float64) (float64, float64, float64) {
+	switch c {
+	case SoftClip:
+		e := extent(intensity)
+		return soften(r, e), soften(g, e), soften(b, e)
+	case PreserveHue:
+		y := math.Min(math.Max(luma(r, g, b), 0), 1)
+		return setLuma(r, g, b, y)
+	default:
+		return r, g, b
+	}
+}
+
+// extent will return the largest value the mix between two colours in 0..1
+// can produce with the given intensity, the smallest is 1-extent.
+func extent(intensity float64) float64 {
+	return math.Max(1, math.Max(intensity, 1-intensity))
+}
+
+// soften will compress a channel from 1-knee..e onto a rational shoulder
+// which ends at 1, and from 1-e..knee onto a toe which ends at 0. Both meet
+// the straight line with the same slope, values beyond 1-e..e are clamped and
+// an extent of 1 leaves the channel untouched.
+func soften(v, e float64) float64 {
+	if e <= 1 {
+		return v
+	}
+
+	l := e - 1 + knee
+	c := (l - knee) / (l * knee)
+
+	switch {
+	case v > 1-knee:
+		s := math.Min(v, e) - (1 - knee)
+		return 1 - knee + s/(1+s*c)
+	case v < knee:
+		s := knee - math.Max(v, 1-e)
+		return knee - s/(1+s*c)
+	default:
+		return v
+	}
+}
@@ -0,0 +1,158 @@
+package lut
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestSoften(t *testing.T) {
+	tests := []struct {
+		in, e, want float64
+	}{
+		{0.5, 2, 0.5},
+		{knee, 2, knee},
+		{1 - knee, 2, 1 - knee},
+		{2, 2, 1},
+		{-1, 2, 0},
+		{3, 2, 1},
+		{-2, 2, 0},
+		{0, 1, 0},
+		{1, 1, 1},
+		{0.95, 1, 0.95},
+		{1.5, 1, 1.5},
+	}
+
+	for _, tt := range tests {
+		if got := soften(tt.in, tt.e); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("soften(%v, %v) = %v, want %v", tt.in, tt.e, got, tt.want)
+		}
+	}
+
+	// Monotonic, and inside of 0..1 up to the extent.
+	prev := -1.0
+
+	for v := -0.99; v < 2; v += 0.01 {
+		got := soften(v, 2)
+
+		if got <= prev || got <= 0 || got >= 1 {
+			t.Fatalf("soften(%v) = %v, after %v", v, got, prev)
+		}
+
+		prev = got
+	}
+}
+
+func TestClipColor(t *testing.T) {
+	colours := [][3]float64{
+		{1.4, 0.6, 0.2},
+		{-0.3, 0.5, 0.9},
+		{2, 2, 1.5},
+		{-1, -0.5, -0.2},
+		{0.3, 0.6, 0.9},
+	}
+
+	for _, c := range colours {
+		r, g, b := clipColor(PreserveHue, 1, c[0], c[1], c[2])
+
+		for _, v := range []float64{r, g, b} {
+			if v < -1e-12 || v > 1+1e-12 {
+				t.Fatalf("clipColor(%v) = %v, %v, %v, want 0..1", c, r, g, b)
+			}
+		}
+
+		// The order of the channels, and so the hue, is kept.
+		if (c[0] < c[1]) != (r < g) && r != g || (c[1] < c[2]) != (g < b) && g != b {
+			t.Errorf("clipColor(%v) = %v, %v, %v changes the hue", c, r, g, b)
+		}
+
+		if r, g, b := clipColor(HardClip, 1, c[0], c[1], c[2]); [3]float64{r, g, b} != c {
+			t.Errorf("clipColor(HardClip, %v) = %v, %v, %v, want it unchanged", c, r, g, b)
+		}
+	}
+
+	if r, g, b := clipColor(PreserveHue, 1, 0.3, 0.6, 0.9); r != 0.3 || g != 0.6 || b != 0.9 {
+		t.Errorf("clipColor() of an in range colour = %v, %v, %v", r, g, b)
+	}
+}
+
+func TestApplyIntensity(t *testing.T) {
+	src := image.NewNRGBA64(image.Rect(0, 0, 1, 1))
+	put16(src.Pix, 0x4000, 0x8000, 0xc000, 0xffff)
+
+	// Inverting gives 0.75, 0.5 and 0.25.
+	tests := []struct {
+		intensity float64
+		clip      Clip
+		want      [3]float64
+	}{
+		{1.25, HardClip, [3]float64{0.875, 0.5, 0.125}},
+		{-0.25, HardClip, [3]float64{0.125, 0.5, 0.875}},
+		{-1, HardClip, [3]float64{0, 0.5, 1}},
+		{-1, SoftClip, [3]float64{soften(-0.25, 2), 0.5, soften(1.25, 2)}},
+		{1, SoftClip, [3]float64{0.75, 0.5, 0.25}},
+		{0.5, HardClip, [3]float64{0.5, 0.5, 0.5}},
+	}
+
+	for _, tt := range tests {
+		var fixed []Option
+		if tt.intensity >= 0 && tt.intensity <= 1 {
+			fixed = append(fixed, WithFixed(invert16{}))
+		}
+
+		out, err := Apply(src, invert, tt.intensity, append(fixed, WithClip(tt.clip))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p := out.(*image.NRGBA64).Pix
+
+		for i, want := range tt.want {
+			got := float64(uint16(p[2*i])<<8|uint16(p[2*i+1])) / 0xffff
+			if math.Abs(got-want) > 2.0/0xffff {
+				t.Errorf("Apply(%v, %d) channel %d = %v, want %v", tt.intensity, tt.clip, i, got, want)
+			}
+		}
+	}
+
+	if _, err := Apply(src, invert, 1, WithClip(PreserveHue+1)); err == nil {
+		t.Error("Apply() expected an error for an invalid clip policy")
+	}
+
+	if _, err := Apply(src, invert, math.Inf(1)); err == nil {
+		t.Error("Apply() expected an error for an infinite intensity")
+	}
+}
+
+func TestSoftClipMask(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	mask := image.NewGray(src.Rect)
+
+	for x, v := range []uint8{0, 1, 128, 255} {
+		src.SetNRGBA(x, 0, color.NRGBA{A: 0xff})
+		src.SetNRGBA(x, 1, color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff})
+		mask.SetGray(x, 0, color.Gray{Y: v})
+		mask.SetGray(x, 1, color.Gray{Y: v})
+	}
+
+	identity := func(r, g, b float64) (float64, float64, float64) { return r, g, b }
+
+	// Intensities within 0..1 never push colours out of range, so black and
+	// white are kept on both sides of the edge of the mask.
+	out, err := Apply(src, identity, 1, WithClip(SoftClip), WithMask(Mask{Image: mask}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, v := range out.(*image.NRGBA).Pix {
+		want := uint8(0)
+		if i%4 == 3 || i >= 16 {
+			want = 0xff
+		}
+
+		if v != want {
+			t.Fatalf("Apply() pixel %d channel %d = %d, want %d", i/4, i%4, v, want)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package lut
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+)
+
+// Dither selects how graded colours are dithered when they are quantized to
+// 8 bits per channel, which hides banding in smooth gradients. Images with
+// 16 bits per channel or floating point channels are never dithered.
+type Dither int
+
+// Supported dithering algorithms.
+const (
+	// NoDither rounds every channel to the nearest code value, this is the
+	// default.
+	NoDither Dither = iota
+	// Ordered dithers using an 8x8 Bayer matrix.
+	Ordered
+	// BlueNoise dithers using a 64x64 blue noise texture, which has no
+	// visible pattern.
+	BlueNoise
+	// FloydSteinberg diffuses the rounding error of each pixel onto its
+	// neighbours. Images are graded in horizontal strips in parallel, and
+	// the error is diffused within each strip.
+	FloydSteinberg
+)
+
+// WithDither will set how colours are dithered when they are quantized to 8
+// bits per channel.
+func WithDither(d Dither) Option {
+	return func(o *options) {
+		o.dither = d
+	}
+}
+
+// quantizer rounds normalised channel values (0..1) to 8 bit code values.
+// Quantizers which diffuse errors are stateful, and expect pixels to be
+// quantized in order, one row at a time.
+type quantizer interface {
+	quantize(x, y, ch int, v float64) uint8
+}
+
+// newQuantizer will create a quantizer for a strip of an image, x0 and width
+// describe the horizontal extent of the strip.
+func newQuantizer(d Dither, x0, width int) quantizer {
+	switch d {
+	case Ordered:
+		return thresholds{bayer(), 8}
+	case BlueNoise:
+		return thresholds{blueNoise(), 64}
+	case FloydSteinberg:
+		return &errorDiffusion{
+			x0:   x0,
+			y:    math.MinInt32,
+			cur:  make([]float64, 3*(width+2)),
+			next: make([]float64, 3*(width+2)),
+		}
+	default:
+		return rounding{}
+	}
+}
+
+type rounding struct{}
+
+func (rounding) quantize(x, y, ch int, v float64) uint8 {
+	return uint8(quantize(v, 0xff))
+}
+
+// thresholds adds a tiled threshold map, with values between -0.5 and 0.5,
+// to every channel before rounding it.
+type thresholds struct {
+	m    []float64
+	size int
+}
+
+func (t thresholds) quantize(x, y, ch int, v float64) uint8 {
+	// Offset each channel by a different amount, so the noise isn't the same
+	// in every channel.
+	x += ch * t.size / 3
+	y += ch * t.size / 5
+
+	i := (y&(t.size-1))*t.size + x&(t.size-1)
+
+	return uint8(quantize(v+t.m[i]/0xff, 0xff))
+}
+
+// errorDiffusion implements Floyd-Steinberg dithering, cur and next hold the
+// error carried into the current and the following row for every channel,
+// padded by a pixel on either side.
+type errorDiffusion struct {
+	x0        int
+	y         int
+	cur, next []float64
+}
+
+func (e *errorDiffusion) quantize(x, y, ch int, v float64) uint8 {
+	if y != e.y {
+		if y == e.y+1 {
+			e.cur, e.next = e.next, e.cur
+		} else {
+			clearFloats(e.cur)
+		}
+
+		clearFloats(e.next)
+
+		e.y = y
+	}
+
+	i := 3*(x-e.x0+1) + ch
+
+	v = math.Max(0, math.Min(1, v))*0xff + e.cur[i]
+	q := quantize(v/0xff, 0xff)
+	err := v - q
+
+	e.cur[i+3] += err * 7 / 16
+	e.next[i-3] += err * 3 / 16
+	e.next[i] += err * 5 / 16
+	e.next[i+3] += err * 1 / 16
+
+	return uint8(q)
+}
+
+func clearFloats(s []float64) {
+	for i := range s {
+		s[i] = 0
+	}
+}
+
+var (
+	bayerOnce sync.Once
+	bayerMap  []float64
+)
+
+// bayer will return an 8x8 Bayer threshold map.
+func bayer() []float64 {
+	bayerOnce.Do(func() {
+		m := []int{0}
+
+		for n := 1; n < 8; n *= 2 {
+			next := make([]int, 4*n*n)
+
+			for y := 0; y < n; y++ {
+				for x := 0; x < n; x++ {
+					v := 4 * m[y*n+x]
+					next[y*2*n+x] = v
+					next[y*2*n+x+n] = v + 2
+					next[(y+n)*2*n+x] = v + 3
+					next[(y+n)*2*n+x+n] = v + 1
+				}
+			}
+
+			m = next
+		}
+
+		bayerMap = ranks(m)
+	})
+
+	return bayerMap
+}
+
+var (
+	blueNoiseOnce sync.Once
+	blueNoiseMap  []float64
+)
+
+// blueNoise will return a 64x64 blue noise threshold map, generated with the
+// void and cluster method the first time it is needed.
+func blueNoise() []float64 {
+	blueNoiseOnce.Do(func() {
+		blueNoiseMap = ranks(voidAndCluster(64, 1.5))
+	})
+
+	return blueNoiseMap
+}
+
+// ranks will convert a permutation of 0..n-1 into thresholds between -0.5
+// and 0.5.
+func ranks(m []int) []float64 {
+	out := make([]float64, len(m))
+
+	for i, v := range m {
+		out[i] = (float64(v)+0.5)/float64(len(m)) - 0.5
+	}
+
+	return out
+}
+
+// voidAndCluster will generate a size x size blue noise dither array, where
+// every cell holds its rank in the order cells are switched on.
+func voidAndCluster(size int, sigma float64) []int {
+	n := size * size
+
+	// Gaussian weight for every toroidal offset.
+	gauss := make([]float64, n)
+
+	for dy := 0; dy < size; dy++ {
+		for dx := 0; dx < size; dx++ {
+			x := math.Min(float64(dx), float64(size-dx))
+			y := math.Min(float64(dy), float64(size-dy))
+			gauss[dy*size+dx] = math.Exp(-(x*x + y*y) / (2 * sigma * sigma))
+		}
+	}
+
+	on := make([]bool, n)
+	energy := make([]float64, n)
+
+	toggle := func(i int, v bool) {
+		on[i] = v
+
+		sign := 1.0
+		if !v {
+			sign = -1
+		}
+
+		ix, iy := i%size, i/size
+
+		for y := 0; y < size; y++ {
+			dy := (y - iy + size) % size
+
+			for x := 0; x < size; x++ {
+				dx := (x - ix + size) % size
+				energy[y*size+x] += sign * gauss[dy*size+dx]
+			}
+		}
+	}
+
+	// tightest cluster is the set cell with the most energy, the largest
+	// void is the unset cell with the least.
+	find := func(set bool) int {
+		best := -1
+
+		for i, v := range on {
+			if v != set {
+				continue
+			}
+
+			if best < 0 || (set && energy[i] > energy[best]) || (!set && energy[i] < energy[best]) {
+				best = i
+			}
+		}
+
+		return best
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+
+	ones := n / 10
+	for _, i := range rnd.Perm(n)[:ones] {
+		toggle(i, true)
+	}
+
+	// Spread the initial pattern out evenly.
+	for {
+		c := find(true)
+		toggle(c, false)
+
+		v := find(false)
+		if v == c {
+			toggle(c, true)
+			break
+		}
+
+		toggle(v, true)
+	}
+
+	initial := make([]bool, n)
+	copy(initial, on)
+
+	rank := make([]int, n)
+
+	for r := ones - 1; r >= 0; r-- {
+		c := find(true)
+		toggle(c, false)
+		rank[c] = r
+	}
+
+	for i, v := range initial {
+		if v != on[i] {
+			toggle(i, v)
+		}
+	}
+
+	for r := ones; r < n; r++ {
+		v := find(false)
+		toggle(v, true)
+		rank[v] = r
+	}
+
+	return rank
+}
@@ -0,0 +1,73 @@
+package lut
+
+import (
+	"image"
+	"math"
+	"sort"
+	"testing"
+)
+
+func TestThresholdMaps(t *testing.T) {
+	for name, m := range map[string][]float64{
+		"bayer":      bayer(),
+		"blue noise": blueNoise(),
+	} {
+		s := append([]float64(nil), m...)
+		sort.Float64s(s)
+
+		for i, v := range s {
+			if want := (float64(i)+0.5)/float64(len(s)) - 0.5; math.Abs(v-want) > 1e-12 {
+				t.Errorf("%s threshold map is not a permutation", name)
+				break
+			}
+		}
+	}
+}
+
+func TestApplyDither(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 128, 128))
+
+	// A colour which falls in between two code values.
+	const want = 100.3
+
+	flat := func(r, g, b float64) (float64, float64, float64) {
+		return want / 0xff, want / 0xff, want / 0xff
+	}
+
+	tests := []struct {
+		dither Dither
+		mean   float64
+	}{
+		{NoDither, 100},
+		{Ordered, want},
+		{BlueNoise, want},
+		{FloydSteinberg, want},
+	}
+
+	for _, tt := range tests {
+		out, err := Apply(src, flat, 1, WithDither(tt.dither))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		img := out.(*image.NRGBA)
+
+		var sum float64
+
+		for i, v := range img.Pix {
+			if i%4 == 3 {
+				continue
+			}
+
+			if v != 100 && v != 101 {
+				t.Fatalf("Apply() with dither %d produced %d, want 100 or 101", tt.dither, v)
+			}
+
+			sum += float64(v)
+		}
+
+		if mean := sum / float64(len(img.Pix)/4*3); math.Abs(mean-tt.mean) > 0.02 {
+			t.Errorf("Apply() with dither %d mean = %v, want %v", tt.dither, mean, tt.mean)
+		}
+	}
+}
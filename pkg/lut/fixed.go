@@ -0,0 +1,202 @@
+package lut
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/wayneashleyberry/lut/pkg/floatimage"
+	"github.com/wayneashleyberry/lut/pkg/parallel"
+)
+
+// Fixed maps a straight colour with 16 bits per channel to a new colour using
+// integer arithmetic only, see trilinear.NewFixed and tetrahedral.NewFixed.
+type Fixed interface {
+	Map16(r, g, b uint16) (uint16, uint16, uint16)
+}
+
+// WithFixed will grade pixels with the integer kernel k instead of the Func
+// passed to Apply. Pixels are read, mixed and written without floating point
+// arithmetic or allocations for *image.NRGBA, *image.RGBA, *image.NRGBA64 and
+// *image.RGBA64 images. Floating point images, dithered output, blend modes
+// other than Normal, clip policies other than HardClip and intensities
+// outside of 0..1 always use the Func.
+func WithFixed(k Fixed) Option {
+	return func(o *options) {
+		o.fixed = k
+	}
+}
+
+// useFixed will report whether the integer kernel can grade from src to dst.
+func useFixed(o options, dst draw.Image, src image.Image, intensity float64) bool {
+	if o.fixed == nil || o.dither != NoDither || o.blend != Normal || o.clip != HardClip {
+		return false
+	}
+
+	if intensity < 0 || intensity > 1 {
+		return false
+	}
+
+	if _, ok := dst.(*floatimage.RGBAF32); ok {
+		return false
+	}
+
+	_, ok := src.(*floatimage.RGBAF32)
+
+	return !ok
+}
+
+// applyFixed is the integer version of the pixel loop in ApplyTo, r and sp
+// have already been clipped. matte is nil when every pixel is graded with
+// the full intensity.
+func applyFixed(dst draw.Image, r image.Rectangle, src image.Image, sp image.Point, o options, intensity float64, matte func(x, y int, r, g, b float64) float64) error {
+	k, alpha := o.fixed, o.alpha
+
+	// intensity in 16.16 fixed point
+	t := uint32(intensity*0x10000 + 0.5)
+
+	width, height := r.Dx(), r.Dy()
+
+	return parallel.Run(o.parallel, height, func(start, end int) {
+		get := reader16(src)
+		set := setter16(dst)
+
+		for y := start; y < end; y++ {
+			for x := 0; x < width; x++ {
+				sr, sg, sb, sa := get(sp.X+x, sp.Y+y)
+
+				t := t
+				if matte != nil {
+					w := matte(sp.X+x, sp.Y+y, float64(sr)/0xffff, float64(sg)/0xffff, float64(sb)/0xffff)
+					t = uint32(intensity*w*0x10000 + 0.5)
+				}
+
+				if t == 0 || sa == 0 && alpha == SkipTransparent {
+					set(r.Min.X+x, r.Min.Y+y, sr, sg, sb, sa)
+					continue
+				}
+
+				if alpha == Premultiplied {
+					sr, sg, sb = mul16(sr, sa), mul16(sg, sa), mul16(sb, sa)
+				}
+
+				lr, lg, lb := k.Map16(uint16(sr), uint16(sg), uint16(sb))
+
+				lr32 := mix16(sr, uint32(lr), t)
+				lg32 := mix16(sg, uint32(lg), t)
+				lb32 := mix16(sb, uint32(lb), t)
+
+				if alpha == Premultiplied {
+					lr32, lg32, lb32 = div16(lr32, sa), div16(lg32, sa), div16(lb32, sa)
+				}
+
+				set(r.Min.X+x, r.Min.Y+y, lr32, lg32, lb32, sa)
+			}
+		}
+	})
+}
+
+// reader16 will return a function which reads the straight colour of a pixel
+// with 16 bits per channel.
+func reader16(img image.Image) func(x, y int) (r, g, b, a uint32) {
+	switch img := img.(type) {
+	case *image.NRGBA:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			c := img.NRGBAAt(x, y)
+			return uint32(c.R) * 0x101, uint32(c.G) * 0x101, uint32(c.B) * 0x101, uint32(c.A) * 0x101
+		}
+	case *image.NRGBA64:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			c := img.NRGBA64At(x, y)
+			return uint32(c.R), uint32(c.G), uint32(c.B), uint32(c.A)
+		}
+	case *image.RGBA:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			c := img.RGBAAt(x, y)
+			a := uint32(c.A) * 0x101
+
+			return div16(uint32(c.R)*0x101, a), div16(uint32(c.G)*0x101, a), div16(uint32(c.B)*0x101, a), a
+		}
+	case *image.RGBA64:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			c := img.RGBA64At(x, y)
+			a := uint32(c.A)
+
+			return div16(uint32(c.R), a), div16(uint32(c.G), a), div16(uint32(c.B), a), a
+		}
+	case *image.YCbCr:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			yi, ci := img.YOffset(x, y), img.COffset(x, y)
+			r, g, b, _ := color.YCbCr{Y: img.Y[yi], Cb: img.Cb[ci], Cr: img.Cr[ci]}.RGBA()
+
+			return r, g, b, 0xffff
+		}
+	case *image.Gray:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			v := uint32(img.Pix[img.PixOffset(x, y)]) * 0x101
+			return v, v, v, 0xffff
+		}
+	default:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			r, g, b, a := img.At(x, y).RGBA()
+			return div16(r, a), div16(g, a), div16(b, a), a
+		}
+	}
+}
+
+// setter16 will return a function which stores a straight colour with 16 bits
+// per channel in img, rounding it to the bit depth and alpha representation of
+// the image.
+func setter16(img draw.Image) func(x, y int, r, g, b, a uint32) {
+	switch img := img.(type) {
+	case *image.NRGBA:
+		return func(x, y int, r, g, b, a uint32) {
+			img.SetNRGBA(x, y, color.NRGBA{R: to8(r), G: to8(g), B: to8(b), A: to8(a)})
+		}
+	case *image.NRGBA64:
+		return func(x, y int, r, g, b, a uint32) {
+			img.SetNRGBA64(x, y, color.NRGBA64{R: uint16(r), G: uint16(g), B: uint16(b), A: uint16(a)})
+		}
+	case *image.RGBA:
+		return func(x, y int, r, g, b, a uint32) {
+			img.SetRGBA(x, y, color.RGBA{R: to8(mul16(r, a)), G: to8(mul16(g, a)), B: to8(mul16(b, a)), A: to8(a)})
+		}
+	case *image.RGBA64:
+		return func(x, y int, r, g, b, a uint32) {
+			img.SetRGBA64(x, y, color.RGBA64{R: uint16(mul16(r, a)), G: uint16(mul16(g, a)), B: uint16(mul16(b, a)), A: uint16(a)})
+		}
+	default:
+		return func(x, y int, r, g, b, a uint32) {
+			img.Set(x, y, color.NRGBA64{R: uint16(r), G: uint16(g), B: uint16(b), A: uint16(a)})
+		}
+	}
+}
+
+// mix16 will blend the 16 bit values a and b, t is the position between them
+// in 16.16 fixed point.
+func mix16(a, b, t uint32) uint32 {
+	return (a*(0x10000-t) + b*t + 0x8000) >> 16
+}
+
+// mul16 will multiply two 16 bit values as if they were normalised to 0..1.
+func mul16(v, a uint32) uint32 {
+	return (v*a + 0x7fff) / 0xffff
+}
+
+// div16 will divide two 16 bit values as if they were normalised to 0..1,
+// clamping the result to 0xffff.
+func div16(v, a uint32) uint32 {
+	switch {
+	case a == 0:
+		return 0
+	case v >= a:
+		return 0xffff
+	}
+
+	return (v*0xffff + a/2) / a
+}
+
+// to8 will round a 16 bit value to the nearest 8 bit value.
+func to8(v uint32) uint8 {
+	return uint8((v*0xff + 0x7fff) / 0xffff)
+}
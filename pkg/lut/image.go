@@ -0,0 +1,160 @@
+package lut
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/wayneashleyberry/lut/pkg/floatimage"
+	"github.com/wayneashleyberry/lut/pkg/transform"
+)
+
+// Transform maps a colour to a new colour, like Func. Types which implement it
+// can be graded lazily with NewImage and NewModel, see the transform package
+// for curves, matrices and chains of transforms.
+type Transform = transform.Transform
+
+// Map will call fn, which makes every Func a Transform.
+func (fn Func) Map(r, g, b float64) (float64, float64, float64) {
+	return fn(r, g, b)
+}
+
+// Image is an image.Image which grades the pixels of another image as they
+// are read, see NewImage.
+type Image struct {
+	src   image.Image
+	fn    Func
+	alpha AlphaMode
+	blend BlendMode
+	clip  Clip
+	matte func(x, y int, r, g, b float64) float64
+	get   func(x, y int) (r, g, b, a float64)
+	model color.Model
+}
+
+// NewImage will wrap src in an image which passes every pixel through t when
+// it is read with At, without allocating a graded copy of src. The alpha
+// mode, blend mode, clip policy, mask and qualifier are taken from the
+// options, dithering and integer kernels don't apply.
+//
+// The colour model has the bit depth of src: floating point sources produce
+// floatimage.Color values, 16 bit sources produce color.NRGBA64 values and
+// everything else produces color.NRGBA values. Pixels are graded every time
+// they are read, so apply functions are faster when an image is read many
+// times.
+func NewImage(src image.Image, t Transform, opts ...Option) *Image {
+	o := newOptions(opts)
+
+	return &Image{
+		src:   src,
+		fn:    t.Map,
+		alpha: o.alpha,
+		blend: o.blend,
+		clip:  o.clip,
+		matte: o.matte(src),
+		get:   reader(src),
+		model: modelOf(src),
+	}
+}
+
+// ColorModel implements image.Image.
+func (m *Image) ColorModel() color.Model {
+	return m.model
+}
+
+// Bounds implements image.Image.
+func (m *Image) Bounds() image.Rectangle {
+	return m.src.Bounds()
+}
+
+// At implements image.Image.
+func (m *Image) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(m.src.Bounds())) {
+		return m.model.Convert(color.Transparent)
+	}
+
+	r, g, b, a := m.get(x, y)
+
+	k := 1.0
+	if m.matte != nil {
+		k = m.matte(x, y, r, g, b)
+	}
+
+	r, g, b = grade(m.fn, k, m.alpha, m.blend, m.clip, r, g, b, a)
+
+	return encode(m.model, r, g, b, a)
+}
+
+// Opaque will report whether the source image is fully opaque, grading never
+// changes alpha.
+func (m *Image) Opaque() bool {
+	if o, ok := m.src.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+
+	return false
+}
+
+// NewModel will create a color.Model which passes colours through t, for
+// example colorcube.Transform. The alpha mode, blend mode, clip policy and
+// qualifier are taken from the options, masks don't apply as colours have no
+// position. Floating point colours are converted to floatimage.Color values
+// without clamping, everything else is converted to color.NRGBA64 values.
+func NewModel(t Transform, opts ...Option) color.Model {
+	o := newOptions(opts)
+	fn := Func(t.Map)
+	qualify := o.qualifier.matte()
+
+	return color.ModelFunc(func(c color.Color) color.Color {
+		model := color.NRGBA64Model
+		if _, ok := c.(floatimage.Color); ok {
+			model = floatimage.Model
+		}
+
+		r, g, b, a := read(c)
+
+		k := 1.0
+		if qualify != nil {
+			k = qualify(r, g, b)
+		}
+
+		r, g, b = grade(fn, k, o.alpha, o.blend, o.clip, r, g, b, a)
+
+		return encode(model, r, g, b, a)
+	})
+}
+
+// modelOf will return the colour model for grading src without losing
+// precision, matching the image types created by Apply.
+func modelOf(src image.Image) color.Model {
+	switch src.(type) {
+	case *floatimage.RGBAF32:
+		return floatimage.Model
+	case *image.RGBA64, *image.NRGBA64, *image.Gray16:
+		return color.NRGBA64Model
+	default:
+		return color.NRGBAModel
+	}
+}
+
+// encode will round a straight colour to a colour of the given model, which
+// is one of the models returned by modelOf.
+func encode(model color.Model, r, g, b, a float64) color.Color {
+	switch model {
+	case floatimage.Model:
+		return floatimage.Color{R: float32(r * a), G: float32(g * a), B: float32(b * a), A: float32(a)}
+	case color.NRGBA64Model:
+		return color.NRGBA64{
+			R: uint16(quantize(r, 0xffff)),
+			G: uint16(quantize(g, 0xffff)),
+			B: uint16(quantize(b, 0xffff)),
+			A: uint16(quantize(a, 0xffff)),
+		}
+	default:
+		return color.NRGBA{
+			R: uint8(quantize(r, 0xff)),
+			G: uint8(quantize(g, 0xff)),
+			B: uint8(quantize(b, 0xff)),
+			A: uint8(quantize(a, 0xff)),
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package lut
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"testing"
+
+	"github.com/wayneashleyberry/lut/pkg/colorcube"
+	"github.com/wayneashleyberry/lut/pkg/floatimage"
+)
+
+func TestNewImage(t *testing.T) {
+	r := image.Rect(3, 5, 67, 37)
+
+	float := floatimage.NewRGBAF32(r)
+	draw.Draw(float, r, sources(r)["nrgba"], r.Min, draw.Src)
+
+	tests := []struct {
+		name  string
+		src   image.Image
+		model color.Model
+		want  draw.Image
+	}{
+		{"nrgba", sources(r)["nrgba"], color.NRGBAModel, image.NewNRGBA(r)},
+		{"ycbcr", sources(r)["ycbcr"], color.NRGBAModel, image.NewNRGBA(r)},
+		{"rgba64", sources(r)["rgba64"], color.NRGBA64Model, image.NewNRGBA64(r)},
+		{"float", float, floatimage.Model, floatimage.NewRGBAF32(r)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := NewImage(tt.src, Func(invert))
+
+			if img.ColorModel() != tt.model || img.Bounds() != r {
+				t.Fatalf("NewImage() has model %v and bounds %v", img.ColorModel(), img.Bounds())
+			}
+
+			if err := ApplyTo(tt.want, r, tt.src, r.Min, invert, 1); err != nil {
+				t.Fatal(err)
+			}
+
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				for x := r.Min.X; x < r.Max.X; x++ {
+					if got, want := img.At(x, y), tt.want.At(x, y); got != want {
+						t.Fatalf("At(%d, %d) = %v, want %v", x, y, got, want)
+					}
+				}
+			}
+
+			if got := img.At(0, 0); got != tt.model.Convert(color.Transparent) {
+				t.Errorf("At() outside of the bounds = %v", got)
+			}
+		})
+	}
+}
+
+func TestNewImageEncode(t *testing.T) {
+	r := image.Rect(0, 0, 32, 32)
+	src := sources(r)["ycbcr"]
+
+	var buf bytes.Buffer
+
+	if err := png.Encode(&buf, NewImage(src, Func(invert))); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := got.(*image.RGBA); !ok {
+		t.Errorf("png.Encode() of an opaque image decoded as %T, want *image.RGBA", got)
+	}
+
+	want, err := Apply(src, invert, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if got, want := color.NRGBAModel.Convert(got.At(x, y)), want.At(x, y); got != want {
+				t.Fatalf("png.Encode() at (%d, %d) = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestNewModel(t *testing.T) {
+	cube := colorcube.New(2, []float64{0, 0, 0}, []float64{1, 1, 1})
+
+	for x := 0; x < 2; x++ {
+		for y := 0; y < 2; y++ {
+			for z := 0; z < 2; z++ {
+				cube.Set(x, y, z, []float64{float64(1 - x), float64(1 - y), float64(1 - z)})
+			}
+		}
+	}
+
+	model := NewModel(colorcube.Transform{Cube: cube})
+
+	tests := []struct {
+		in   color.Color
+		want color.Color
+	}{
+		{color.NRGBA{0xff, 0, 0xff, 0xff}, color.NRGBA64{0, 0xffff, 0, 0xffff}},
+		{color.RGBA{0x80, 0x80, 0, 0x80}, color.NRGBA64{0, 0, 0xffff, 0x8080}},
+		{floatimage.Color{R: 0, G: 1, B: 0.5, A: 0.5}, floatimage.Color{R: 0.5, G: 0, B: 0, A: 0.5}},
+	}
+
+	for _, tt := range tests {
+		if got := model.Convert(tt.in); got != tt.want {
+			t.Errorf("Convert(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
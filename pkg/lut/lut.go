@@ -0,0 +1,474 @@
+// Package lut contains the pixel loop shared by the interpolation packages,
+// every pixel of a source image is passed through a colour transformation and
+// mixed with the original colour according to an intensity.
+package lut
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"reflect"
+
+	"github.com/wayneashleyberry/lut/pkg/floatimage"
+	"github.com/wayneashleyberry/lut/pkg/parallel"
+)
+
+// Func maps a colour to a new colour. Channels are normalised so 0..1 is the
+// displayable range, but floating point images can contain values outside of
+// that range.
+type Func func(r, g, b float64) (float64, float64, float64)
+
+// Apply will create a new image by passing every pixel in src through fn,
+// taking the intensity multiplier into account. The new image has the same
+// bounds as src, along with the same bit depth and alpha representation:
+// *image.RGBA, *image.RGBA64, *image.NRGBA64 and *floatimage.RGBAF32 sources
+// produce an image of the same type, other 16 bit sources produce an
+// *image.NRGBA64 and everything else produces an *image.NRGBA.
+//
+// An *image.Paletted source is graded by passing only its palette through fn,
+// the result is an *image.Paletted with the same indices and a new palette.
+// Masked paletted sources are graded pixel by pixel instead.
+func Apply(src image.Image, fn Func, intensity float64, opts ...Option) (image.Image, error) {
+	if p, ok := src.(*image.Paletted); ok && newOptions(opts).mask.Image == nil {
+		return applyPalette(p, fn, intensity, opts...)
+	}
+
+	bounds := src.Bounds()
+
+	out := newImage(src, bounds)
+
+	if err := ApplyTo(out, bounds, src, bounds.Min, fn, intensity, opts...); err != nil {
+		return src, err
+	}
+
+	return out, nil
+}
+
+// ApplyTo will pass the pixels of src through fn and store the result in the
+// rectangle r of dst, taking the intensity multiplier into account. The point
+// sp in src is aligned with r.Min in dst, exactly like draw.Draw, and r is
+// clipped to the bounds of both images. Pixels of dst outside of r are left
+// untouched, and dst may be the same image as src to grade it in place. Like
+// draw.Draw, overlapping areas of the same image are read before any of them
+// are written, which takes a copy of the source area.
+func ApplyTo(dst draw.Image, r image.Rectangle, src image.Image, sp image.Point, fn Func, intensity float64, opts ...Option) error {
+	o := newOptions(opts)
+
+	if err := validate(intensity, o); err != nil {
+		return err
+	}
+
+	r, sp = clip(dst, r, src, sp)
+	if r.Empty() {
+		return nil
+	}
+
+	matte := o.matte(src)
+
+	if overlaps(dst, r, src, sp) {
+		src = copyArea(src, image.Rectangle{sp, sp.Add(r.Size())})
+	}
+
+	if useFixed(o, dst, src, intensity) {
+		return applyFixed(dst, r, src, sp, o, intensity, matte)
+	}
+
+	width, height := r.Dx(), r.Dy()
+
+	return parallel.Run(o.parallel, height, func(start, end int) {
+		get := reader(src)
+		set := setter(dst, newQuantizer(o.dither, r.Min.X, width))
+
+		for y := start; y < end; y++ {
+			for x := 0; x < width; x++ {
+				sr, sg, sb, sa := get(sp.X+x, sp.Y+y)
+
+				k := intensity
+				if matte != nil {
+					k *= matte(sp.X+x, sp.Y+y, sr, sg, sb)
+				}
+
+				lr, lg, lb := grade(fn, k, o.alpha, o.blend, o.clip, sr, sg, sb, sa)
+
+				set(r.Min.X+x, r.Min.Y+y, lr, lg, lb, sa)
+			}
+		}
+	})
+}
+
+// validate will check the intensity and options shared by every apply
+// function. Any finite intensity is valid, the clip policy decides what
+// happens to colours which are pushed out of range.
+func validate(intensity float64, o options) error {
+	if math.IsNaN(intensity) || math.IsInf(intensity, 0) {
+		return errors.New("intensity must be a finite number")
+	}
+
+	if o.clip < HardClip || o.clip > PreserveHue {
+		return errors.New("invalid clip policy")
+	}
+
+	if err := o.qualifier.validate(); err != nil {
+		return err
+	}
+
+	if o.alpha < Straight || o.alpha > SkipTransparent {
+		return errors.New("invalid alpha mode")
+	}
+
+	if o.dither < NoDither || o.dither > FloydSteinberg {
+		return errors.New("invalid dither")
+	}
+
+	if o.mask.Channel < MaskLuma || o.mask.Channel > MaskAlpha {
+		return errors.New("invalid mask channel")
+	}
+
+	if o.blend < Normal || o.blend > Screen {
+		return errors.New("invalid blend mode")
+	}
+
+	return nil
+}
+
+// grade will pass a straight colour through fn, blend the result with the
+// original colour and mix the two according to the intensity and alpha mode.
+// Intensities outside of 0..1 extrapolate the mix, and the result is brought
+// back into range by the clip policy. fn is not called when the intensity is
+// 0, but the clip policy still applies so masked edges have no seams.
+func grade(fn Func, intensity float64, alpha AlphaMode, mode BlendMode, clip Clip, r, g, b, a float64) (float64, float64, float64) {
+	if a == 0 && alpha == SkipTransparent {
+		return r, g, b
+	}
+
+	if intensity == 0 {
+		return clipColor(clip, 0, r, g, b)
+	}
+
+	if alpha == Premultiplied {
+		r, g, b = r*a, g*a, b*a
+	}
+
+	lr, lg, lb := fn(r, g, b)
+	lr, lg, lb = blend(mode, r, g, b, lr, lg, lb)
+
+	lr = r*(1-intensity) + lr*intensity
+	lg = g*(1-intensity) + lg*intensity
+	lb = b*(1-intensity) + lb*intensity
+
+	if alpha == Premultiplied {
+		lr, lg, lb = unpremultiply(lr, lg, lb, a)
+	}
+
+	return clipColor(clip, intensity, lr, lg, lb)
+}
+
+// applyPalette will grade the palette of src, keeping its pixels untouched.
+// Dithering and integer kernels don't apply to palettes, every entry is
+// passed through fn and rounded to 8 bits.
+func applyPalette(src *image.Paletted, fn Func, intensity float64, opts ...Option) (image.Image, error) {
+	o := newOptions(opts)
+
+	if err := validate(intensity, o); err != nil {
+		return src, err
+	}
+
+	qualify := o.qualifier.matte()
+	palette := make(color.Palette, len(src.Palette))
+
+	for i, c := range src.Palette {
+		r, g, b, a := read(c)
+
+		k := intensity
+		if qualify != nil {
+			k *= qualify(r, g, b)
+		}
+
+		r, g, b = grade(fn, k, o.alpha, o.blend, o.clip, r, g, b, a)
+
+		palette[i] = color.NRGBA{
+			R: uint8(quantize(r, 0xff)),
+			G: uint8(quantize(g, 0xff)),
+			B: uint8(quantize(b, 0xff)),
+			A: uint8(quantize(a, 0xff)),
+		}
+	}
+
+	out := image.NewPaletted(src.Rect, palette)
+	copy(out.Pix, src.Pix)
+
+	return out, nil
+}
+
+// clip will clip r to the bounds of dst, and the corresponding rectangle
+// starting at sp to the bounds of src.
+func clip(dst draw.Image, r image.Rectangle, src image.Image, sp image.Point) (image.Rectangle, image.Point) {
+	orig := r.Min
+
+	r = r.Intersect(dst.Bounds())
+	r = r.Intersect(src.Bounds().Add(orig.Sub(sp)))
+
+	return r, sp.Add(r.Min.Sub(orig))
+}
+
+// overlaps will report whether dst may share pixels with src and the clipped
+// rectangle r overlaps the area it is read from, without being that area.
+func overlaps(dst draw.Image, r image.Rectangle, src image.Image, sp image.Point) bool {
+	return sp != r.Min && r.Overlaps(r.Add(sp.Sub(r.Min))) && aliases(dst, src)
+}
+
+// aliases will report whether dst and src may share pixels. Images with a Pix
+// slice share pixels when their slices have the same backing array, which
+// includes sub-images. Images are never compared with ==, which panics for
+// types that aren't comparable, so other images are assumed to share pixels
+// when they have the same type.
+func aliases(dst draw.Image, src image.Image) bool {
+	if d, ok := dst.(*floatimage.RGBAF32); ok {
+		s, ok := src.(*floatimage.RGBAF32)
+		return ok && cap(d.Pix) > 0 && cap(s.Pix) > 0 &&
+			&d.Pix[:cap(d.Pix)][cap(d.Pix)-1] == &s.Pix[:cap(s.Pix)][cap(s.Pix)-1]
+	}
+
+	if d, ok := pix(dst); ok {
+		s, ok := pix(src)
+		return ok && cap(d) > 0 && cap(s) > 0 && &d[:cap(d)][cap(d)-1] == &s[:cap(s)][cap(s)-1]
+	}
+
+	return reflect.TypeOf(dst) == reflect.TypeOf(src)
+}
+
+// pix will return the Pix slice of the standard library image types.
+func pix(img image.Image) ([]uint8, bool) {
+	switch img := img.(type) {
+	case *image.NRGBA:
+		return img.Pix, true
+	case *image.RGBA:
+		return img.Pix, true
+	case *image.NRGBA64:
+		return img.Pix, true
+	case *image.RGBA64:
+		return img.Pix, true
+	case *image.Gray:
+		return img.Pix, true
+	case *image.Gray16:
+		return img.Pix, true
+	case *image.Alpha:
+		return img.Pix, true
+	case *image.Alpha16:
+		return img.Pix, true
+	case *image.CMYK:
+		return img.Pix, true
+	case *image.Paletted:
+		return img.Pix, true
+	default:
+		return nil, false
+	}
+}
+
+// copyArea will copy the rectangle r of src to a new image with the same bit
+// depth and alpha representation, and the same coordinates.
+func copyArea(src image.Image, r image.Rectangle) image.Image {
+	out := newImage(src, r)
+	draw.Draw(out, r, src, r.Min, draw.Src)
+
+	return out
+}
+
+// newImage will create an image with the same bit depth and alpha
+// representation as src.
+func newImage(src image.Image, r image.Rectangle) draw.Image {
+	switch src.(type) {
+	case *floatimage.RGBAF32:
+		return floatimage.NewRGBAF32(r)
+	case *image.RGBA:
+		return image.NewRGBA(r)
+	case *image.RGBA64:
+		return image.NewRGBA64(r)
+	case *image.NRGBA64, *image.Gray16:
+		return image.NewNRGBA64(r)
+	default:
+		return image.NewNRGBA(r)
+	}
+}
+
+// reader will return a function which reads the straight colour of a pixel
+// in img, like read. Common image types are read directly from their Pix
+// slices, which avoids boxing every pixel in a color.Color, anything else
+// falls back to At.
+func reader(img image.Image) func(x, y int) (r, g, b, a float64) {
+	switch img := img.(type) {
+	case *image.NRGBA:
+		return func(x, y int) (float64, float64, float64, float64) {
+			i := img.PixOffset(x, y)
+			p := img.Pix[i : i+4 : i+4]
+
+			return float64(p[0]) / 0xff, float64(p[1]) / 0xff, float64(p[2]) / 0xff, float64(p[3]) / 0xff
+		}
+	case *image.RGBA:
+		return func(x, y int) (float64, float64, float64, float64) {
+			i := img.PixOffset(x, y)
+			p := img.Pix[i : i+4 : i+4]
+			a := float64(p[3]) / 0xff
+			r, g, b := unpremultiply(float64(p[0])/0xff, float64(p[1])/0xff, float64(p[2])/0xff, a)
+
+			return r, g, b, a
+		}
+	case *image.NRGBA64:
+		return func(x, y int) (float64, float64, float64, float64) {
+			i := img.PixOffset(x, y)
+			p := img.Pix[i : i+8 : i+8]
+
+			return float64(uint16(p[0])<<8|uint16(p[1])) / 0xffff,
+				float64(uint16(p[2])<<8|uint16(p[3])) / 0xffff,
+				float64(uint16(p[4])<<8|uint16(p[5])) / 0xffff,
+				float64(uint16(p[6])<<8|uint16(p[7])) / 0xffff
+		}
+	case *image.RGBA64:
+		return func(x, y int) (float64, float64, float64, float64) {
+			i := img.PixOffset(x, y)
+			p := img.Pix[i : i+8 : i+8]
+			a := float64(uint16(p[6])<<8|uint16(p[7])) / 0xffff
+			r, g, b := unpremultiply(
+				float64(uint16(p[0])<<8|uint16(p[1]))/0xffff,
+				float64(uint16(p[2])<<8|uint16(p[3]))/0xffff,
+				float64(uint16(p[4])<<8|uint16(p[5]))/0xffff,
+				a,
+			)
+
+			return r, g, b, a
+		}
+	case *image.YCbCr:
+		return func(x, y int) (float64, float64, float64, float64) {
+			yi, ci := img.YOffset(x, y), img.COffset(x, y)
+			r, g, b, _ := color.YCbCr{Y: img.Y[yi], Cb: img.Cb[ci], Cr: img.Cr[ci]}.RGBA()
+
+			return float64(r) / 0xffff, float64(g) / 0xffff, float64(b) / 0xffff, 1
+		}
+	case *image.Gray:
+		return func(x, y int) (float64, float64, float64, float64) {
+			v := float64(img.Pix[img.PixOffset(x, y)]) / 0xff
+			return v, v, v, 1
+		}
+	default:
+		return func(x, y int) (float64, float64, float64, float64) {
+			return read(img.At(x, y))
+		}
+	}
+}
+
+// read will return the straight (non-premultiplied) colour of c, normalised to
+// 0..1. Colours which are already straight are read without a round trip
+// through premultiplied alpha, and floating point colours are not clamped.
+func read(c color.Color) (r, g, b, a float64) {
+	switch c := c.(type) {
+	case color.NRGBA:
+		return float64(c.R) / 0xff, float64(c.G) / 0xff, float64(c.B) / 0xff, float64(c.A) / 0xff
+	case color.NRGBA64:
+		return float64(c.R) / 0xffff, float64(c.G) / 0xffff, float64(c.B) / 0xffff, float64(c.A) / 0xffff
+	case floatimage.Color:
+		r, g, b := unpremultiply(float64(c.R), float64(c.G), float64(c.B), float64(c.A))
+		return r, g, b, float64(c.A)
+	default:
+		pr, pg, pb, pa := c.RGBA()
+		a := float64(pa) / 0xffff
+		r, g, b := unpremultiply(float64(pr)/0xffff, float64(pg)/0xffff, float64(pb)/0xffff, a)
+
+		return r, g, b, a
+	}
+}
+
+func unpremultiply(r, g, b, a float64) (float64, float64, float64) {
+	if a == 0 {
+		return 0, 0, 0
+	}
+
+	return r / a, g / a, b / a
+}
+
+// setter will return a function which stores a straight colour in img,
+// rounding it to the bit depth and alpha representation of the image. Images
+// with 8 bits per channel are quantized by q.
+func setter(img draw.Image, q quantizer) func(x, y int, r, g, b, a float64) {
+	switch img := img.(type) {
+	case *floatimage.RGBAF32:
+		return func(x, y int, r, g, b, a float64) {
+			img.SetRGBAF32(x, y, floatimage.Color{
+				R: float32(r * a),
+				G: float32(g * a),
+				B: float32(b * a),
+				A: float32(a),
+			})
+		}
+	case *image.RGBA64:
+		return func(x, y int, r, g, b, a float64) {
+			i := img.PixOffset(x, y)
+			put16(img.Pix[i:i+8:i+8],
+				uint16(quantize(r*a, 0xffff)),
+				uint16(quantize(g*a, 0xffff)),
+				uint16(quantize(b*a, 0xffff)),
+				uint16(quantize(a, 0xffff)),
+			)
+		}
+	case *image.NRGBA64:
+		return func(x, y int, r, g, b, a float64) {
+			i := img.PixOffset(x, y)
+			put16(img.Pix[i:i+8:i+8],
+				uint16(quantize(r, 0xffff)),
+				uint16(quantize(g, 0xffff)),
+				uint16(quantize(b, 0xffff)),
+				uint16(quantize(a, 0xffff)),
+			)
+		}
+	case *image.RGBA:
+		return func(x, y int, r, g, b, a float64) {
+			i := img.PixOffset(x, y)
+			p := img.Pix[i : i+4 : i+4]
+			p[0] = q.quantize(x, y, 0, r*a)
+			p[1] = q.quantize(x, y, 1, g*a)
+			p[2] = q.quantize(x, y, 2, b*a)
+			p[3] = uint8(quantize(a, 0xff))
+		}
+	case *image.NRGBA:
+		return func(x, y int, r, g, b, a float64) {
+			i := img.PixOffset(x, y)
+			p := img.Pix[i : i+4 : i+4]
+			p[0] = q.quantize(x, y, 0, r)
+			p[1] = q.quantize(x, y, 1, g)
+			p[2] = q.quantize(x, y, 2, b)
+			p[3] = uint8(quantize(a, 0xff))
+		}
+	default:
+		return func(x, y int, r, g, b, a float64) {
+			img.Set(x, y, color.NRGBA64{
+				R: uint16(quantize(r, 0xffff)),
+				G: uint16(quantize(g, 0xffff)),
+				B: uint16(quantize(b, 0xffff)),
+				A: uint16(quantize(a, 0xffff)),
+			})
+		}
+	}
+}
+
+// put16 will store four 16 bit channels in big endian order, as used by the
+// Pix slices of *image.RGBA64 and *image.NRGBA64.
+func put16(p []uint8, r, g, b, a uint16) {
+	p[0], p[1] = uint8(r>>8), uint8(r)
+	p[2], p[3] = uint8(g>>8), uint8(g)
+	p[4], p[5] = uint8(b>>8), uint8(b)
+	p[6], p[7] = uint8(a>>8), uint8(a)
+}
+
+// quantize will scale a normalised channel value to the nearest code value
+// between 0 and max.
+func quantize(v, max float64) float64 {
+	switch v = v*max + 0.5; {
+	case v <= 0:
+		return 0
+	case v >= max:
+		return max
+	default:
+		return float64(int(v))
+	}
+}
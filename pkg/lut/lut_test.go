@@ -0,0 +1,291 @@
+package lut
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/wayneashleyberry/lut/pkg/floatimage"
+)
+
+func identity(r, g, b float64) (float64, float64, float64) {
+	return r, g, b
+}
+
+func TestApply(t *testing.T) {
+	rect := image.Rect(0, 0, 64, 16)
+
+	nrgba64 := image.NewNRGBA64(rect)
+	rgba64 := image.NewRGBA64(rect)
+	gray16 := image.NewGray16(rect)
+	nrgba := image.NewNRGBA(rect)
+
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			v := uint16(x*1021 + y*7)
+			nrgba64.SetNRGBA64(x, y, color.NRGBA64{v, 0xffff - v, v / 2, 0xffff})
+			rgba64.SetRGBA64(x, y, color.RGBA64{v, 0xffff - v, v / 2, 0xffff})
+			gray16.SetGray16(x, y, color.Gray16{v})
+			nrgba.SetNRGBA(x, y, color.NRGBA{uint8(x * 4), uint8(y * 16), 0x80, 0xff})
+		}
+	}
+
+	tests := []struct {
+		name string
+		src  image.Image
+		want image.Image
+	}{
+		{"nrgba64", nrgba64, &image.NRGBA64{}},
+		{"rgba64", rgba64, &image.RGBA64{}},
+		{"gray16", gray16, &image.NRGBA64{}},
+		{"nrgba", nrgba, &image.NRGBA{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := Apply(tt.src, identity, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got, want := out.ColorModel(), tt.want.ColorModel(); got != want {
+				t.Fatalf("Apply() returned %T, want %T", out, tt.want)
+			}
+
+			for y := rect.Min.Y; y < rect.Max.Y; y++ {
+				for x := rect.Min.X; x < rect.Max.X; x++ {
+					got := color.NRGBA64Model.Convert(out.At(x, y))
+					want := color.NRGBA64Model.Convert(tt.src.At(x, y))
+
+					if got != want {
+						t.Fatalf("Apply() at (%d, %d) = %v, want %v", x, y, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestApplyFloat(t *testing.T) {
+	src := floatimage.NewRGBAF32(image.Rect(0, 0, 2, 1))
+	src.SetRGBAF32(0, 0, floatimage.Color{R: 2, G: 0.5, B: -0.25, A: 1})
+	src.SetRGBAF32(1, 0, floatimage.Color{R: 0.5, G: 0.25, B: 1.5, A: 0.5})
+
+	double := func(r, g, b float64) (float64, float64, float64) {
+		return r * 2, g * 2, b * 2
+	}
+
+	out, err := Apply(src, double, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, ok := out.(*floatimage.RGBAF32)
+	if !ok {
+		t.Fatalf("Apply() returned %T, want *floatimage.RGBAF32", out)
+	}
+
+	want := []floatimage.Color{
+		{R: 3, G: 0.75, B: -0.375, A: 1},
+		{R: 0.75, G: 0.375, B: 2.25, A: 0.5},
+	}
+
+	for x, w := range want {
+		if got := img.RGBAF32At(x, 0); got != w {
+			t.Errorf("Apply() at (%d, 0) = %v, want %v", x, got, w)
+		}
+	}
+}
+
+func invert(r, g, b float64) (float64, float64, float64) {
+	return 1 - r, 1 - g, 1 - b
+}
+
+func TestApplySubImage(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{uint8(x * 8), uint8(y * 8), 0, 0xff})
+		}
+	}
+
+	sub := img.SubImage(image.Rect(8, 4, 24, 20))
+
+	out, err := Apply(sub, invert, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if out.Bounds() != sub.Bounds() {
+		t.Fatalf("Apply() bounds = %v, want %v", out.Bounds(), sub.Bounds())
+	}
+
+	for y := 4; y < 20; y++ {
+		for x := 8; x < 24; x++ {
+			want := color.NRGBA{255 - uint8(x*8), 255 - uint8(y*8), 255, 0xff}
+			if got := out.At(x, y); got != want {
+				t.Fatalf("Apply() at (%d, %d) = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestApplyTo(t *testing.T) {
+	canvas := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			canvas.SetNRGBA(x, y, color.NRGBA{uint8(x * 8), uint8(y * 8), 0, 0xff})
+		}
+	}
+
+	orig := image.NewNRGBA(canvas.Bounds())
+	copy(orig.Pix, canvas.Pix)
+
+	// Grade a rectangle which hangs off the edge of the canvas in place.
+	r := image.Rect(16, -8, 48, 8)
+
+	if err := ApplyTo(canvas, r, canvas, r.Min, invert, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			c := orig.NRGBAAt(x, y)
+			if (image.Point{x, y}).In(r) {
+				c = color.NRGBA{255 - c.R, 255 - c.G, 255 - c.B, c.A}
+			}
+
+			if got := canvas.NRGBAAt(x, y); got != c {
+				t.Fatalf("ApplyTo() at (%d, %d) = %v, want %v", x, y, got, c)
+			}
+		}
+	}
+}
+
+func TestApplyToOverlap(t *testing.T) {
+	canvas := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			canvas.SetNRGBA(x, y, color.NRGBA{uint8(x * 8), uint8(y * 8), 0, 0xff})
+		}
+	}
+
+	orig := image.NewNRGBA(canvas.Bounds())
+	copy(orig.Pix, canvas.Pix)
+
+	// Grade every row from the row above it, one row at a time.
+	r := image.Rect(0, 1, 32, 32)
+
+	if err := ApplyTo(canvas, r, canvas, image.Pt(0, 0), invert, 1, WithChunk(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			c := orig.NRGBAAt(x, y)
+			if y > 0 {
+				c = orig.NRGBAAt(x, y-1)
+				c = color.NRGBA{255 - c.R, 255 - c.G, 255 - c.B, c.A}
+			}
+
+			if got := canvas.NRGBAAt(x, y); got != c {
+				t.Fatalf("ApplyTo() at (%d, %d) = %v, want %v", x, y, got, c)
+			}
+		}
+	}
+}
+
+// valueImage is an image which isn't comparable, as it is stored by value
+// and holds a slice.
+type valueImage struct {
+	pix  []color.NRGBA
+	size int
+}
+
+func (m valueImage) ColorModel() color.Model { return color.NRGBAModel }
+
+func (m valueImage) Bounds() image.Rectangle { return image.Rect(0, 0, m.size, m.size) }
+
+func (m valueImage) At(x, y int) color.Color { return m.pix[y*m.size+x] }
+
+func (m valueImage) Set(x, y int, c color.Color) {
+	m.pix[y*m.size+x] = color.NRGBAModel.Convert(c).(color.NRGBA)
+}
+
+func TestApplyToValueImage(t *testing.T) {
+	canvas := valueImage{pix: make([]color.NRGBA, 16), size: 4}
+
+	for i := range canvas.pix {
+		canvas.pix[i] = color.NRGBA{uint8(i * 16), 0, 0, 0xff}
+	}
+
+	orig := append([]color.NRGBA(nil), canvas.pix...)
+
+	if err := ApplyTo(canvas, canvas.Bounds(), canvas, image.Point{}, invert, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// Grade every row from the row above it.
+	if err := ApplyTo(canvas, image.Rect(0, 1, 4, 4), canvas, image.Point{}, invert, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, got := range canvas.pix {
+		want := color.NRGBA{255 - orig[i].R, 0xff, 0xff, 0xff}
+		if i >= 4 {
+			want = orig[i-4]
+		}
+
+		if got != want {
+			t.Fatalf("ApplyTo() pixel %d = %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestApplyParallel(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 64, 100))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := Apply(src, invert, 1, WithContext(ctx)); err != context.Canceled {
+		t.Errorf("Apply() with a cancelled context error = %v, want %v", err, context.Canceled)
+	}
+
+	if _, err := Apply(src, invert, 1, WithContext(ctx), WithFixed(invert16{})); err != context.Canceled {
+		t.Errorf("Apply() with a cancelled context error = %v, want %v", err, context.Canceled)
+	}
+
+	var calls, rows int
+
+	out, err := Apply(src, invert, 1, WithWorkers(1), WithChunk(7), WithProgress(func(done, total int) {
+		calls++
+		rows = done
+
+		if total != 100 {
+			t.Errorf("Progress() total = %d, want 100", total)
+		}
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 15 || rows != 100 {
+		t.Errorf("Progress() called %d times with %d rows, want 15 times with 100 rows", calls, rows)
+	}
+
+	if got := out.At(63, 99); got != (color.NRGBA{0xff, 0xff, 0xff, 0}) {
+		t.Errorf("Apply() at (63, 99) = %v", got)
+	}
+}
+
+// invert16 is an integer version of invert.
+type invert16 struct{}
+
+func (invert16) Map16(r, g, b uint16) (uint16, uint16, uint16) {
+	return 0xffff - r, 0xffff - g, 0xffff - b
+}
@@ -0,0 +1,141 @@
+package lut
+
+import (
+	"image"
+	"math"
+)
+
+// MaskChannel selects the channel of a mask image which sets the intensity.
+type MaskChannel int
+
+// Supported mask channels.
+const (
+	// MaskLuma reads the Rec. 709 luma of each mask pixel, this is the
+	// default and suits grayscale masks.
+	MaskLuma MaskChannel = iota
+	// MaskAlpha reads the alpha channel of each mask pixel.
+	MaskAlpha
+)
+
+// Mask sets the intensity of every pixel from an image, white (or opaque)
+// pixels are graded with the full intensity and black (or transparent) pixels
+// are left untouched.
+type Mask struct {
+	Image   image.Image
+	Channel MaskChannel
+	// Invert grades the black or transparent parts of the mask instead.
+	Invert bool
+	// Bounds is the area of the source image covered by the mask, an empty
+	// rectangle covers the bounds of the source. The mask is resampled
+	// bilinearly when it has a different size.
+	Bounds image.Rectangle
+}
+
+// WithMask will multiply the intensity of every pixel by the value of the
+// mask at the same position. Images graded a part at a time, like the strips
+// of the stream package, should set the Bounds of the mask to the full image.
+func WithMask(m Mask) Option {
+	return func(o *options) {
+		o.mask = m
+	}
+}
+
+// sampler will return a function which returns the value of the mask at a
+// pixel of src, or nil when there is no mask.
+func (m Mask) sampler(src image.Image) func(x, y int) float64 {
+	if m.Image == nil {
+		return nil
+	}
+
+	mb := m.Image.Bounds()
+
+	sb := m.Bounds
+	if sb.Empty() {
+		sb = src.Bounds()
+	}
+
+	if mb.Empty() || sb.Empty() {
+		return nil
+	}
+
+	get := reader(m.Image)
+
+	value := func(x, y int) float64 {
+		r, g, b, a := get(x, y)
+
+		var v float64
+
+		if m.Channel == MaskAlpha {
+			v = a
+		} else {
+			v = luma(r, g, b)
+		}
+
+		switch {
+		case v < 0:
+			v = 0
+		case v > 1:
+			v = 1
+		}
+
+		if m.Invert {
+			v = 1 - v
+		}
+
+		return v
+	}
+
+	if mb.Size() == sb.Size() {
+		d := mb.Min.Sub(sb.Min)
+
+		return func(x, y int) float64 {
+			return value(clampPoint(image.Pt(x, y).Add(d), mb))
+		}
+	}
+
+	// Pixel centres of the source are mapped onto pixel centres of the mask.
+	kx := float64(mb.Dx()) / float64(sb.Dx())
+	ky := float64(mb.Dy()) / float64(sb.Dy())
+
+	return func(x, y int) float64 {
+		fx := (float64(x-sb.Min.X)+0.5)*kx - 0.5
+		fy := (float64(y-sb.Min.Y)+0.5)*ky - 0.5
+
+		x0, y0 := math.Floor(fx), math.Floor(fy)
+		dx, dy := fx-x0, fy-y0
+
+		p00 := image.Pt(mb.Min.X+int(x0), mb.Min.Y+int(y0))
+		p11 := p00.Add(image.Pt(1, 1))
+
+		v00 := value(clampPoint(p00, mb))
+		v10 := value(clampPoint(image.Pt(p11.X, p00.Y), mb))
+		v01 := value(clampPoint(image.Pt(p00.X, p11.Y), mb))
+		v11 := value(clampPoint(p11, mb))
+
+		return (v00*(1-dx)+v10*dx)*(1-dy) + (v01*(1-dx)+v11*dx)*dy
+	}
+}
+
+// clampPoint will move p to the nearest pixel inside of r.
+func clampPoint(p image.Point, r image.Rectangle) (int, int) {
+	switch {
+	case p.X < r.Min.X:
+		p.X = r.Min.X
+	case p.X >= r.Max.X:
+		p.X = r.Max.X - 1
+	}
+
+	switch {
+	case p.Y < r.Min.Y:
+		p.Y = r.Min.Y
+	case p.Y >= r.Max.Y:
+		p.Y = r.Max.Y - 1
+	}
+
+	return p.X, p.Y
+}
+
+// luma will return the Rec. 709 luma of a colour.
+func luma(r, g, b float64) float64 {
+	return 0.2126*r + 0.7152*g + 0.0722*b
+}
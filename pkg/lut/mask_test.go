@@ -0,0 +1,105 @@
+package lut
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestMaskSampler(t *testing.T) {
+	gray := image.NewGray(image.Rect(10, 10, 12, 11))
+	gray.Pix[1] = 0xff
+
+	alpha := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	alpha.SetNRGBA(0, 0, color.NRGBA{0xff, 0xff, 0xff, 0})
+	alpha.SetNRGBA(1, 0, color.NRGBA{0, 0, 0, 0xff})
+
+	src := image.Rect(0, 0, 4, 1)
+
+	tests := []struct {
+		name string
+		mask Mask
+		src  image.Rectangle
+		want []float64
+	}{
+		{"same size", Mask{Image: gray}, image.Rect(5, 0, 7, 1), []float64{0, 1}},
+		{"invert", Mask{Image: gray, Invert: true}, image.Rect(5, 0, 7, 1), []float64{1, 0}},
+		{"alpha", Mask{Image: alpha, Channel: MaskAlpha}, image.Rect(5, 0, 7, 1), []float64{0, 1}},
+		{"luma", Mask{Image: alpha}, image.Rect(5, 0, 7, 1), []float64{1, 0}},
+		{"resampled", Mask{Image: gray}, src, []float64{0, 0.25, 0.75, 1}},
+		{"bounds", Mask{Image: gray, Bounds: image.Rect(0, 0, 8, 1)}, src, []float64{0, 0, 0.125, 0.375}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sample := tt.mask.sampler(image.NewNRGBA(tt.src))
+
+			for i, want := range tt.want {
+				if got := sample(tt.src.Min.X+i, 0); math.Abs(got-want) > 1e-9 {
+					t.Errorf("mask at %d = %v, want %v", i, got, want)
+				}
+			}
+		})
+	}
+
+	if (Mask{}).sampler(gray) != nil {
+		t.Error("Mask.sampler() without an image should return nil")
+	}
+}
+
+func TestApplyMask(t *testing.T) {
+	rect := image.Rect(0, 0, 32, 8)
+
+	src := image.NewNRGBA(rect)
+	for i := range src.Pix {
+		src.Pix[i] = uint8(i * 7)
+	}
+
+	for i := 3; i < len(src.Pix); i += 4 {
+		src.Pix[i] = 0xff
+	}
+
+	// Grade the left half at full intensity, a smaller mask is resampled.
+	mask := image.NewGray(image.Rect(0, 0, 4, 1))
+	mask.Pix[0], mask.Pix[1] = 0xff, 0xff
+
+	for _, fixed := range []bool{false, true} {
+		opts := []Option{WithMask(Mask{Image: mask})}
+		if fixed {
+			opts = append(opts, WithFixed(invert16{}))
+		}
+
+		out, err := Apply(src, invert, 1, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				s := src.NRGBAAt(x, y)
+				got := out.(*image.NRGBA).NRGBAAt(x, y)
+
+				want := s
+				if x < 12 {
+					want = color.NRGBA{0xff - s.R, 0xff - s.G, 0xff - s.B, s.A}
+				}
+
+				if x < 12 || x >= 20 {
+					if got != want {
+						t.Fatalf("fixed %v: Apply() at (%d, %d) = %v, want %v", fixed, x, y, got, want)
+					}
+				}
+			}
+		}
+	}
+
+	img := NewImage(src, Func(invert), WithMask(Mask{Image: mask, Invert: true}))
+	if got, want := img.At(31, 0), color.NRGBAModel.Convert(color.NRGBA{0xff - src.Pix[124], 0xff - src.Pix[125], 0xff - src.Pix[126], 0xff}); got != want {
+		t.Errorf("NewImage().At() = %v, want %v", got, want)
+	}
+
+	if _, err := Apply(src, invert, 1, WithMask(Mask{Image: mask, Channel: -1})); err == nil {
+		t.Error("Apply() expected an error for an invalid mask channel")
+	}
+}
@@ -0,0 +1,92 @@
+package lut
+
+import (
+	"context"
+
+	"github.com/wayneashleyberry/lut/pkg/parallel"
+)
+
+// Option configures how a transformation is applied to an image.
+type Option func(*options)
+
+type options struct {
+	alpha  AlphaMode
+	dither Dither
+	fixed  Fixed
+	mask   Mask
+	blend  BlendMode
+	clip   Clip
+
+	qualifier Qualifier
+
+	parallel parallel.Config
+}
+
+func newOptions(opts []Option) options {
+	o := options{}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// AlphaMode controls how the colour of partially transparent pixels is
+// graded.
+type AlphaMode int
+
+// Supported alpha modes.
+const (
+	// Straight grades the colour of each pixel before it is multiplied by
+	// alpha, this is the default.
+	Straight AlphaMode = iota
+	// Premultiplied grades the colour of each pixel after it has been
+	// multiplied by alpha, as a compositor would see it.
+	Premultiplied
+	// SkipTransparent grades straight colours, but leaves fully transparent
+	// pixels untouched.
+	SkipTransparent
+)
+
+// WithAlpha will set how partially transparent pixels are graded.
+func WithAlpha(mode AlphaMode) Option {
+	return func(o *options) {
+		o.alpha = mode
+	}
+}
+
+// WithContext will stop applying a transformation when ctx is cancelled, the
+// apply functions then return the error of the context and the destination
+// image is left partially graded.
+func WithContext(ctx context.Context) Option {
+	return func(o *options) {
+		o.parallel.Context = ctx
+	}
+}
+
+// WithWorkers will limit the number of goroutines used to apply a
+// transformation, zero or less uses one per available CPU.
+func WithWorkers(n int) Option {
+	return func(o *options) {
+		o.parallel.Workers = n
+	}
+}
+
+// WithChunk will set the number of rows a goroutine grades at a time, zero or
+// less gives every goroutine a few chunks. Floyd-Steinberg dithering only
+// diffuses errors within a chunk.
+func WithChunk(rows int) Option {
+	return func(o *options) {
+		o.parallel.Chunk = rows
+	}
+}
+
+// WithProgress will call fn every time a chunk of rows has been graded, with
+// the number of graded rows and the total number of rows. Calls never
+// overlap, but they are made from the goroutines grading the image.
+func WithProgress(fn func(done, total int)) Option {
+	return func(o *options) {
+		o.parallel.Progress = fn
+	}
+}
@@ -0,0 +1,69 @@
+package lut
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestApplyPalette(t *testing.T) {
+	palette := color.Palette{
+		color.RGBA{0, 0, 0, 0xff},
+		color.RGBA{0xff, 0x80, 0x00, 0xff},
+		color.NRGBA{0x20, 0x40, 0xc0, 0x80},
+		color.Transparent,
+	}
+
+	src := image.NewPaletted(image.Rect(0, 0, 16, 4), palette)
+	for i := range src.Pix {
+		src.Pix[i] = uint8(i % len(palette))
+	}
+
+	tests := []struct {
+		name  string
+		alpha AlphaMode
+		want  color.Palette
+	}{
+		{"straight", Straight, color.Palette{
+			color.NRGBA{0xff, 0xff, 0xff, 0xff},
+			color.NRGBA{0x00, 0x7f, 0xff, 0xff},
+			color.NRGBA{0xdf, 0xbf, 0x3f, 0x80},
+			color.NRGBA{0xff, 0xff, 0xff, 0x00},
+		}},
+		{"skip", SkipTransparent, color.Palette{
+			color.NRGBA{0xff, 0xff, 0xff, 0xff},
+			color.NRGBA{0x00, 0x7f, 0xff, 0xff},
+			color.NRGBA{0xdf, 0xbf, 0x3f, 0x80},
+			color.NRGBA{0x00, 0x00, 0x00, 0x00},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := Apply(src, invert, 1, WithAlpha(tt.alpha))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			p, ok := out.(*image.Paletted)
+			if !ok {
+				t.Fatalf("Apply() returned %T, want *image.Paletted", out)
+			}
+
+			if p.Rect != src.Rect || string(p.Pix) != string(src.Pix) {
+				t.Fatal("Apply() changed the palette indices")
+			}
+
+			for i, c := range p.Palette {
+				if c != tt.want[i] {
+					t.Errorf("Apply() palette[%d] = %v, want %v", i, c, tt.want[i])
+				}
+			}
+		})
+	}
+
+	if _, err := Apply(src, invert, math.NaN()); err == nil {
+		t.Error("Apply() expected an error for an invalid intensity")
+	}
+}
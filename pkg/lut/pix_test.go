@@ -0,0 +1,123 @@
+package lut
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"testing"
+)
+
+// generic hides the concrete type of an image, forcing the fallback path
+// through At.
+type generic struct {
+	image.Image
+}
+
+// sources will return an image of every type with a fast path, filled with
+// the same partially transparent pattern where the type supports alpha.
+func sources(r image.Rectangle) map[string]image.Image {
+	pattern := image.NewNRGBA(r)
+
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			pattern.SetNRGBA(x, y, color.NRGBA{uint8(x * 3), uint8(y * 5), uint8(x ^ y), uint8(0xff - x)})
+		}
+	}
+
+	ycbcr := image.NewYCbCr(r, image.YCbCrSubsampleRatio420)
+
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			ycbcr.Y[ycbcr.YOffset(x, y)] = uint8(x * 3)
+			ycbcr.Cb[ycbcr.COffset(x, y)] = uint8(y * 5)
+			ycbcr.Cr[ycbcr.COffset(x, y)] = uint8(x ^ y)
+		}
+	}
+
+	imgs := map[string]image.Image{
+		"nrgba":   pattern,
+		"rgba":    image.NewRGBA(r),
+		"nrgba64": image.NewNRGBA64(r),
+		"rgba64":  image.NewRGBA64(r),
+		"gray":    image.NewGray(r),
+		"ycbcr":   ycbcr,
+	}
+
+	for _, name := range []string{"rgba", "nrgba64", "rgba64", "gray"} {
+		draw.Draw(imgs[name].(draw.Image), r, pattern, r.Min, draw.Src)
+	}
+
+	return imgs
+}
+
+func TestReader(t *testing.T) {
+	r := image.Rect(3, 5, 67, 37)
+
+	for name, img := range sources(r) {
+		t.Run(name, func(t *testing.T) {
+			get := reader(img)
+
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				for x := r.Min.X; x < r.Max.X; x++ {
+					gr, gg, gb, ga := get(x, y)
+					wr, wg, wb, wa := read(img.At(x, y))
+
+					for _, d := range []float64{gr - wr, gg - wg, gb - wb, ga - wa} {
+						if math.Abs(d) > 1e-9 {
+							t.Fatalf("reader() at (%d, %d) = %v, %v, %v, %v, want %v, %v, %v, %v", x, y, gr, gg, gb, ga, wr, wg, wb, wa)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSetter(t *testing.T) {
+	r := image.Rect(3, 5, 7, 9)
+
+	tests := []struct {
+		dst  draw.Image
+		want color.Color
+	}{
+		{image.NewNRGBA(r), color.NRGBA{54, 110, 222, 156}},
+		{image.NewRGBA(r), color.RGBA{33, 67, 135, 156}},
+		{image.NewNRGBA64(r), color.NRGBA64{13762, 28180, 57015, 39976}},
+		{image.NewRGBA64(r), color.RGBA64{8395, 17190, 34779, 39976}},
+	}
+
+	for _, tt := range tests {
+		setter(tt.dst, rounding{})(4, 6, 0.21, 0.43, 0.87, 0.61)
+
+		if got := tt.dst.At(4, 6); got != tt.want {
+			t.Errorf("setter(%T) = %v, want %v", tt.dst, got, tt.want)
+		}
+
+		if got := tt.dst.At(5, 6); got != tt.dst.ColorModel().Convert(color.Transparent) {
+			t.Errorf("setter(%T) changed a neighbouring pixel to %v", tt.dst, got)
+		}
+	}
+}
+
+func BenchmarkApply(b *testing.B) {
+	r := image.Rect(0, 0, 512, 512)
+
+	for name, img := range sources(r) {
+		for _, bm := range []struct {
+			name string
+			src  image.Image
+		}{
+			{name, img},
+			{name + "/generic", generic{img}},
+		} {
+			b.Run(bm.name, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					if _, err := Apply(bm.src, identity, 1); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
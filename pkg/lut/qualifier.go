@@ -0,0 +1,204 @@
+package lut
+
+import (
+	"errors"
+	"image"
+	"math"
+)
+
+// Range selects values between Min and Max. Values up to Softness beyond
+// either end are partially selected, fading out smoothly. The zero Range
+// selects every value.
+type Range struct {
+	Min, Max float64
+	Softness float64
+}
+
+// Qualifier limits grading to colours inside a hue, saturation and luma
+// range, like the qualifier of a grading application. Hue is measured in
+// degrees from 0 to 360 and wraps around, so a hue range with Min above Max,
+// like 340 to 20, selects reds. Saturation is the HSL saturation and luma is
+// the Rec. 709 luma of the original colour, both from 0 to 1. Greys have no
+// hue and are never selected by a hue range.
+type Qualifier struct {
+	Hue        Range
+	Saturation Range
+	Luma       Range
+}
+
+// WithQualifier will multiply the intensity of every pixel by how well its
+// original colour matches the qualifier, a soft matte which is combined with
+// any mask.
+func WithQualifier(q Qualifier) Option {
+	return func(o *options) {
+		o.qualifier = q
+	}
+}
+
+// validate will check the ranges of the qualifier.
+func (q Qualifier) validate() error {
+	for _, r := range []Range{q.Hue, q.Saturation, q.Luma} {
+		if r.Softness < 0 || math.IsNaN(r.Softness) || math.IsNaN(r.Min) || math.IsNaN(r.Max) {
+			return errors.New("invalid qualifier range")
+		}
+	}
+
+	if q.Saturation.Min > q.Saturation.Max || q.Luma.Min > q.Luma.Max {
+		return errors.New("invalid qualifier range")
+	}
+
+	return nil
+}
+
+// matte will return how well a straight colour matches the qualifier, from 0
+// to 1, or nil when the qualifier selects every colour.
+func (q Qualifier) matte() func(r, g, b float64) float64 {
+	if q == (Qualifier{}) {
+		return nil
+	}
+
+	return func(r, g, b float64) float64 {
+		w := 1.0
+
+		if q.Luma != (Range{}) {
+			w *= q.Luma.weight(luma(r, g, b))
+		}
+
+		if w == 0 || q.Hue == (Range{}) && q.Saturation == (Range{}) {
+			return w
+		}
+
+		h, s := hueSaturation(r, g, b)
+
+		if q.Saturation != (Range{}) {
+			w *= q.Saturation.weight(s)
+		}
+
+		// Greys have no hue, so a hue range never selects them.
+		if q.Hue != (Range{}) && w != 0 {
+			if s == 0 {
+				return 0
+			}
+
+			w *= q.Hue.hueWeight(h)
+		}
+
+		return w
+	}
+}
+
+// weight will return how well v is selected by the range.
+func (r Range) weight(v float64) float64 {
+	var d float64
+
+	switch {
+	case v < r.Min:
+		d = r.Min - v
+	case v > r.Max:
+		d = v - r.Max
+	default:
+		return 1
+	}
+
+	return fade(d, r.Softness)
+}
+
+// hueWeight is weight for hues in degrees, measuring distances around the
+// colour wheel.
+func (r Range) hueWeight(h float64) float64 {
+	if r.Max-r.Min >= 360 {
+		return 1
+	}
+
+	min, max := wrap(r.Min), wrap(r.Max)
+
+	if min <= max && h >= min && h <= max || min > max && (h >= min || h <= max) {
+		return 1
+	}
+
+	d := math.Min(wrap(min-h), wrap(h-max))
+
+	return fade(d, r.Softness)
+}
+
+// fade will smoothly fall from 1 to 0 as d goes from 0 to softness.
+func fade(d, softness float64) float64 {
+	if d >= softness {
+		return 0
+	}
+
+	t := 1 - d/softness
+
+	return t * t * (3 - 2*t)
+}
+
+// wrap will bring an angle in degrees into 0..360.
+func wrap(deg float64) float64 {
+	deg = math.Mod(deg, 360)
+	if deg < 0 {
+		deg += 360
+	}
+
+	return deg
+}
+
+// hueSaturation will return the HSL hue in degrees and the HSL saturation of
+// a colour. Channels are clamped to 0..1 first, greys have a hue of 0.
+func hueSaturation(r, g, b float64) (float64, float64) {
+	r, g, b = clamp01(r), clamp01(g), clamp01(b)
+
+	max := math.Max(r, math.Max(g, b))
+	min := math.Min(r, math.Min(g, b))
+	c := max - min
+
+	if c == 0 {
+		return 0, 0
+	}
+
+	var h float64
+
+	switch max {
+	case r:
+		h = (g - b) / c
+	case g:
+		h = (b-r)/c + 2
+	default:
+		h = (r-g)/c + 4
+	}
+
+	l := (max + min) / 2
+
+	return wrap(h * 60), c / (1 - math.Abs(2*l-1))
+}
+
+func clamp01(v float64) float64 {
+	return math.Min(math.Max(v, 0), 1)
+}
+
+// matte will return the combined mask and qualifier matte of the options for
+// grading src, which takes the position and straight colour of a pixel, or
+// nil when every pixel is graded with the full intensity.
+func (o options) matte(src image.Image) func(x, y int, r, g, b float64) float64 {
+	mask, qualify := o.mask.sampler(src), o.qualifier.matte()
+
+	switch {
+	case mask == nil && qualify == nil:
+		return nil
+	case qualify == nil:
+		return func(x, y int, r, g, b float64) float64 {
+			return mask(x, y)
+		}
+	case mask == nil:
+		return func(x, y int, r, g, b float64) float64 {
+			return qualify(r, g, b)
+		}
+	default:
+		return func(x, y int, r, g, b float64) float64 {
+			if w := mask(x, y); w != 0 {
+				return w * qualify(r, g, b)
+			}
+
+			return 0
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package lut
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestHueSaturation(t *testing.T) {
+	tests := []struct {
+		r, g, b float64
+		h, s    float64
+	}{
+		{1, 0, 0, 0, 1},
+		{0, 1, 0, 120, 1},
+		{0, 0, 1, 240, 1},
+		{1, 0, 1, 300, 1},
+		{0.75, 0.25, 0.25, 0, 0.5},
+		{0.5, 0.5, 0.5, 0, 0},
+		{2, -1, 0, 0, 1},
+	}
+
+	for _, tt := range tests {
+		h, s := hueSaturation(tt.r, tt.g, tt.b)
+		if math.Abs(h-tt.h) > 1e-9 || math.Abs(s-tt.s) > 1e-9 {
+			t.Errorf("hueSaturation(%v, %v, %v) = %v, %v, want %v, %v", tt.r, tt.g, tt.b, h, s, tt.h, tt.s)
+		}
+	}
+}
+
+func TestRange(t *testing.T) {
+	tests := []struct {
+		name string
+		r    Range
+		v    float64
+		hue  bool
+		want float64
+	}{
+		{"inside", Range{0.2, 0.4, 0.1}, 0.3, false, 1},
+		{"edge", Range{0.2, 0.4, 0.1}, 0.4, false, 1},
+		{"soft", Range{0.2, 0.4, 0.1}, 0.45, false, 0.5},
+		{"outside", Range{0.2, 0.4, 0.1}, 0.55, false, 0},
+		{"hard", Range{0.2, 0.4, 0}, 0.41, false, 0},
+		{"hue", Range{20, 50, 10}, 35, true, 1},
+		{"hue soft", Range{20, 50, 10}, 15, true, 0.5},
+		{"hue wraps", Range{340, 20, 10}, 5, true, 1},
+		{"hue wraps soft", Range{340, 20, 10}, 335, true, 0.5},
+		{"hue around", Range{-20, 20, 10}, 355, true, 1},
+		{"hue across zero", Range{10, 50, 20}, 0, true, 0.5},
+		{"hue outside", Range{340, 20, 10}, 180, true, 0},
+		{"hue full", Range{0, 360, 0}, 123, true, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.r.weight(tt.v)
+			if tt.hue {
+				got = tt.r.hueWeight(tt.v)
+			}
+
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("weight(%v) = %v, want %v", tt.v, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyQualifier(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 4, 1))
+	src.SetNRGBA(0, 0, color.NRGBA{0xff, 0x20, 0x20, 0xff}) // red
+	src.SetNRGBA(1, 0, color.NRGBA{0x20, 0x20, 0xff, 0xff}) // blue
+	src.SetNRGBA(2, 0, color.NRGBA{0x80, 0x80, 0x80, 0xff}) // grey
+	src.SetNRGBA(3, 0, color.NRGBA{0x40, 0x08, 0x08, 0xff}) // dark red
+
+	q := Qualifier{
+		Hue:  Range{Min: 340, Max: 20},
+		Luma: Range{Min: 0.2, Max: 1},
+	}
+
+	graded := []bool{true, false, false, false}
+
+	for _, fixed := range []bool{false, true} {
+		opts := []Option{WithQualifier(q)}
+		if fixed {
+			opts = append(opts, WithFixed(invert16{}))
+		}
+
+		out, err := Apply(src, invert, 1, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for x, want := range graded {
+			s := src.NRGBAAt(x, 0)
+			got := out.(*image.NRGBA).NRGBAAt(x, 0)
+
+			if (got != s) != want {
+				t.Errorf("fixed %v: Apply() at %d = %v from %v, graded %v", fixed, x, got, s, want)
+			}
+		}
+	}
+
+	// Palettes are qualified entry by entry.
+	p := image.NewPaletted(src.Rect, color.Palette{src.At(0, 0), src.At(1, 0)})
+
+	out, err := Apply(p, invert, 1, WithQualifier(q))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := out.(*image.Paletted).Palette; got[0] == p.Palette[0] || got[1] != p.Palette[1] {
+		t.Errorf("Apply() palette = %v, want only the first entry graded", got)
+	}
+
+	for _, q := range []Qualifier{
+		{Luma: Range{Min: 0.5, Max: 0.2}},
+		{Saturation: Range{Max: 1, Softness: -1}},
+		{Hue: Range{Min: math.NaN()}},
+	} {
+		if _, err := Apply(src, invert, 1, WithQualifier(q)); err == nil {
+			t.Errorf("Apply() expected an error for qualifier %v", q)
+		}
+	}
+}
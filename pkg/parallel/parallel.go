@@ -1,47 +1,120 @@
 // Package parallel was taken from github.com/anthonynsimon/bild
 // https://git.io/fjOJ2
+//
+// It has since grown into a small worker pool which splits work into chunks,
+// can be cancelled and reports its progress.
 package parallel
 
 import (
+	"context"
 	"runtime"
 	"sync"
+	"sync/atomic"
 )
 
-func init() {
-	runtime.GOMAXPROCS(runtime.NumCPU())
+// chunksPerWorker is the number of chunks each worker gets when no chunk size
+// is configured, more than one evens out chunks which take longer than others.
+const chunksPerWorker = 4
+
+// Config controls how work is split between goroutines, the zero value uses
+// one worker per available CPU and can't be cancelled.
+type Config struct {
+	// Context cancels the work, chunks which have already started are
+	// finished but no new chunks are started.
+	Context context.Context
+	// Workers is the maximum number of goroutines, zero or less uses
+	// runtime.GOMAXPROCS.
+	Workers int
+	// Chunk is the length of the parts the work is split into, zero or less
+	// picks a length which gives every worker a few chunks.
+	Chunk int
+	// Progress is called after every chunk with the total length of the
+	// finished chunks. Calls never overlap, but they are made from the
+	// worker goroutines.
+	Progress func(done, total int)
 }
 
-// Line dispatches a parameter fn into multiple goroutines by splitting the parameter length
-// by the number of available CPUs and assigning the length parts into each fn.
-func Line(length int, fn func(start, end int)) {
-	procs := runtime.GOMAXPROCS(0)
-	counter := length
-	partSize := length / procs
+// Run dispatches fn into multiple goroutines by splitting length into chunks,
+// each call to fn handles the part from start up to end. Run returns once all
+// started chunks have finished, the error is the error of the context if it
+// was cancelled before all chunks were started.
+func Run(cfg Config, length int, fn func(start, end int)) error {
+	ctx := cfg.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
 
-	if procs <= 1 || partSize <= procs {
-		fn(0, length)
-		return
+	if length <= 0 {
+		return ctx.Err()
 	}
 
-	var wg sync.WaitGroup
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
 
-	for counter > 0 {
-		start := counter - partSize
-		end := counter
+	chunk := cfg.Chunk
+	if chunk <= 0 {
+		chunk = (length + workers*chunksPerWorker - 1) / (workers * chunksPerWorker)
+	}
 
-		if start < 0 {
-			start = 0
-		}
+	chunks := (length + chunk - 1) / chunk
+	if workers > chunks {
+		workers = chunks
+	}
+
+	var (
+		next int64 = -1
+		done int
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+	)
 
-		counter -= partSize
+	work := func() {
+		defer wg.Done()
 
-		wg.Add(1)
+		for ctx.Err() == nil {
+			i := int(atomic.AddInt64(&next, 1))
+			if i >= chunks {
+				return
+			}
+
+			start := i * chunk
+			end := start + chunk
+
+			if end > length {
+				end = length
+			}
 
-		go func() {
-			defer wg.Done()
 			fn(start, end)
-		}()
+
+			if cfg.Progress != nil {
+				mu.Lock()
+				done += end - start
+				cfg.Progress(done, length)
+				mu.Unlock()
+			}
+		}
+	}
+
+	wg.Add(workers)
+
+	for i := 1; i < workers; i++ {
+		go work()
 	}
 
+	work()
 	wg.Wait()
+
+	if int(next) < chunks-1 {
+		return ctx.Err()
+	}
+
+	return nil
+}
+
+// Line dispatches a parameter fn into multiple goroutines by splitting the parameter length
+// by the number of available CPUs and assigning the length parts into each fn.
+func Line(length int, fn func(start, end int)) {
+	_ = Run(Config{}, length, fn)
 }
@@ -0,0 +1,92 @@
+package parallel
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    Config
+		length int
+	}{
+		{"default", Config{}, 1000},
+		{"short", Config{}, 3},
+		{"empty", Config{}, 0},
+		{"serial", Config{Workers: 1}, 100},
+		{"chunks", Config{Workers: 3, Chunk: 7}, 100},
+		{"large chunks", Config{Workers: 8, Chunk: 1000}, 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+
+			seen := make([]int, tt.length)
+			last := 0
+
+			cfg := tt.cfg
+			cfg.Progress = func(done, total int) {
+				if done <= last || total != tt.length {
+					t.Errorf("Progress(%d, %d) after %d", done, total, last)
+				}
+
+				last = done
+			}
+
+			err := Run(cfg, tt.length, func(start, end int) {
+				if cfg.Chunk > 0 && end-start > cfg.Chunk {
+					t.Errorf("fn(%d, %d) is longer than the chunk size", start, end)
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+
+				for i := start; i < end; i++ {
+					seen[i]++
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i, n := range seen {
+				if n != 1 {
+					t.Fatalf("Run() visited %d %d times", i, n)
+				}
+			}
+
+			if last != tt.length {
+				t.Errorf("Run() reported progress %d, want %d", last, tt.length)
+			}
+		})
+	}
+}
+
+func TestRunCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	calls := 0
+
+	err := Run(Config{Context: ctx, Workers: 1, Chunk: 1}, 100, func(start, end int) {
+		calls++
+
+		if calls == 10 {
+			cancel()
+		}
+	})
+
+	if err != context.Canceled {
+		t.Errorf("Run() error = %v, want %v", err, context.Canceled)
+	}
+
+	if calls != 10 {
+		t.Errorf("Run() made %d calls after cancelling, want 10", calls)
+	}
+
+	if err := Run(Config{Context: ctx}, 0, func(start, end int) {}); err != context.Canceled {
+		t.Errorf("Run() error = %v, want %v", err, context.Canceled)
+	}
+}
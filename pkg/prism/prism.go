@@ -0,0 +1,70 @@
+// Package prism implements prism interpolation
+package prism
+
+import (
+	"image"
+	"image/draw"
+
+	"github.com/wayneashleyberry/lut/pkg/colorcube"
+	"github.com/wayneashleyberry/lut/pkg/lut"
+)
+
+// Interpolate will apply color transformations to the provided image using
+// prism interpolation (taking the intensity multiplier into account).
+func Interpolate(src image.Image, cube colorcube.Cube, intensity float64, opts ...lut.Option) (image.Image, error) {
+	return lut.Apply(src, func(r, g, b float64) (float64, float64, float64) {
+		return Lookup(cube, r, g, b)
+	}, intensity, opts...)
+}
+
+// ApplyTo will apply color transformations to the rectangle r of dst using
+// prism interpolation, reading from src starting at sp (taking the intensity
+// multiplier into account). See lut.ApplyTo for details.
+func ApplyTo(dst draw.Image, r image.Rectangle, src image.Image, sp image.Point, cube colorcube.Cube, intensity float64, opts ...lut.Option) error {
+	return lut.ApplyTo(dst, r, src, sp, func(r, g, b float64) (float64, float64, float64) {
+		return Lookup(cube, r, g, b)
+	}, intensity, opts...)
+}
+
+// Lookup will return the colour at the given point in the domain of the cube.
+//
+// The cell surrounding the point is split into two triangular prisms along the
+// plane where red equals green, the point is interpolated linearly inside the
+// triangles at either end of its prism and then along the blue axis.
+func Lookup(cube colorcube.Cube, r, g, b float64) (float64, float64, float64) {
+	if r, g, b, ok := cube.Extrapolate(Lookup, r, g, b); ok {
+		return r, g, b
+	}
+
+	r0, r1, dr := cube.Cell(0, r)
+	g0, g1, dg := cube.Cell(1, g)
+	b0, b1, db := cube.Cell(2, b)
+
+	c000 := cube.Get(r0, g0, b0)
+	c001 := cube.Get(r0, g0, b1)
+	c110 := cube.Get(r1, g1, b0)
+	c111 := cube.Get(r1, g1, b1)
+
+	var (
+		c0, c1 []float64
+		d0, d1 float64
+	)
+
+	if dr >= dg {
+		c0, c1 = cube.Get(r1, g0, b0), cube.Get(r1, g0, b1)
+		d0, d1 = dr, dg
+	} else {
+		c0, c1 = cube.Get(r0, g1, b0), cube.Get(r0, g1, b1)
+		d0, d1 = dg, dr
+	}
+
+	var out [3]float64
+
+	for i := range out {
+		bottom := c000[i] + d0*(c0[i]-c000[i]) + d1*(c110[i]-c0[i])
+		top := c001[i] + d0*(c1[i]-c001[i]) + d1*(c111[i]-c1[i])
+		out[i] = bottom + db*(top-bottom)
+	}
+
+	return out[0], out[1], out[2]
+}
@@ -0,0 +1,16 @@
+package prism
+
+import (
+	"testing"
+
+	"github.com/wayneashleyberry/lut/pkg/interptest"
+	"github.com/wayneashleyberry/lut/pkg/trilinear"
+)
+
+func TestLookup(t *testing.T) {
+	interptest.Check(t, Lookup, trilinear.Lookup)
+}
+
+func TestImages(t *testing.T) {
+	interptest.CheckImages(t, interptest.Filter(t, "DU04.cube"), Lookup, trilinear.Lookup, 2)
+}
@@ -0,0 +1,87 @@
+// Package pyramid implements pyramidal interpolation
+package pyramid
+
+import (
+	"image"
+	"image/draw"
+
+	"github.com/wayneashleyberry/lut/pkg/colorcube"
+	"github.com/wayneashleyberry/lut/pkg/lut"
+)
+
+// Interpolate will apply color transformations to the provided image using
+// pyramidal interpolation (taking the intensity multiplier into account).
+func Interpolate(src image.Image, cube colorcube.Cube, intensity float64, opts ...lut.Option) (image.Image, error) {
+	return lut.Apply(src, func(r, g, b float64) (float64, float64, float64) {
+		return Lookup(cube, r, g, b)
+	}, intensity, opts...)
+}
+
+// ApplyTo will apply color transformations to the rectangle r of dst using
+// pyramidal interpolation, reading from src starting at sp (taking the
+// intensity multiplier into account). See lut.ApplyTo for details.
+func ApplyTo(dst draw.Image, r image.Rectangle, src image.Image, sp image.Point, cube colorcube.Cube, intensity float64, opts ...lut.Option) error {
+	return lut.ApplyTo(dst, r, src, sp, func(r, g, b float64) (float64, float64, float64) {
+		return Lookup(cube, r, g, b)
+	}, intensity, opts...)
+}
+
+// Lookup will return the colour at the given point in the domain of the cube.
+//
+// The cell surrounding the point is split into three pyramids which share the
+// brightest corner as their apex, their square bases are the three faces of
+// the cell that touch the darkest corner. The point is projected from the apex
+// onto the base of its pyramid, interpolated bilinearly on the base and then
+// linearly between the base and the apex, which keeps the result continuous
+// across the faces shared by neighbouring pyramids.
+func Lookup(cube colorcube.Cube, r, g, b float64) (float64, float64, float64) {
+	if r, g, b, ok := cube.Extrapolate(Lookup, r, g, b); ok {
+		return r, g, b
+	}
+
+	r0, r1, dr := cube.Cell(0, r)
+	g0, g1, dg := cube.Cell(1, g)
+	b0, b1, db := cube.Cell(2, b)
+
+	c000 := cube.Get(r0, g0, b0)
+	c111 := cube.Get(r1, g1, b1)
+
+	// The base of the pyramid is the face where the smallest offset is zero,
+	// cu and cv are the corners along the base's u and v axes and cuv is the
+	// corner opposite the darkest one.
+	var (
+		cu, cv, cuv []float64
+		s, u, v     float64
+	)
+
+	switch {
+	case dr <= dg && dr <= db:
+		cu, cv, cuv = cube.Get(r0, g1, b0), cube.Get(r0, g0, b1), cube.Get(r0, g1, b1)
+		s, u, v = dr, dg, db
+	case dg <= db:
+		cu, cv, cuv = cube.Get(r1, g0, b0), cube.Get(r0, g0, b1), cube.Get(r1, g0, b1)
+		s, u, v = dg, dr, db
+	default:
+		cu, cv, cuv = cube.Get(r1, g0, b0), cube.Get(r0, g1, b0), cube.Get(r1, g1, b0)
+		s, u, v = db, dr, dg
+	}
+
+	if s >= 1 {
+		return c111[0], c111[1], c111[2]
+	}
+
+	// Offsets from the darkest corner along the base, scaled by (1 - s).
+	u, v = u-s, v-s
+	uv := u * v / (1 - s)
+
+	var out [3]float64
+
+	for i := range out {
+		out[i] = (1-s)*c000[i] + s*c111[i] +
+			u*(cu[i]-c000[i]) +
+			v*(cv[i]-c000[i]) +
+			uv*(cuv[i]-cu[i]-cv[i]+c000[i])
+	}
+
+	return out[0], out[1], out[2]
+}
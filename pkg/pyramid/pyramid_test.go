@@ -0,0 +1,49 @@
+package pyramid
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/wayneashleyberry/lut/pkg/colorcube"
+	"github.com/wayneashleyberry/lut/pkg/interptest"
+	"github.com/wayneashleyberry/lut/pkg/trilinear"
+)
+
+func TestLookup(t *testing.T) {
+	interptest.Check(t, Lookup, trilinear.Lookup)
+}
+
+func TestImages(t *testing.T) {
+	interptest.CheckImages(t, interptest.Filter(t, "DU04.cube"), Lookup, trilinear.Lookup, 2)
+}
+
+func TestLookupContinuity(t *testing.T) {
+	cube := colorcube.New(2, []float64{0, 0, 0}, []float64{1, 1, 1})
+
+	rnd := rand.New(rand.NewSource(1))
+
+	for x := 0; x < 2; x++ {
+		for y := 0; y < 2; y++ {
+			for z := 0; z < 2; z++ {
+				cube.Set(x, y, z, []float64{rnd.Float64(), rnd.Float64(), rnd.Float64()})
+			}
+		}
+	}
+
+	const eps = 1e-9
+
+	// Points either side of the planes which separate the three pyramids.
+	for _, p := range [][2][3]float64{
+		{{0.3, 0.3 + eps, 0.6}, {0.3 + eps, 0.3, 0.6}},
+		{{0.2, 0.7, 0.2 + eps}, {0.2 + eps, 0.7, 0.2}},
+		{{0.8, 0.4, 0.4 + eps}, {0.8, 0.4 + eps, 0.4}},
+	} {
+		ar, ag, ab := Lookup(cube, p[0][0], p[0][1], p[0][2])
+		br, bg, bb := Lookup(cube, p[1][0], p[1][1], p[1][2])
+
+		if math.Abs(ar-br) > 1e-6 || math.Abs(ag-bg) > 1e-6 || math.Abs(ab-bb) > 1e-6 {
+			t.Errorf("Lookup() is discontinuous between %v and %v", p[0], p[1])
+		}
+	}
+}
@@ -0,0 +1,716 @@
+package stream
+
+import (
+	"bufio"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"image"
+	"io"
+	"io/ioutil"
+)
+
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+// PNG colour types.
+const (
+	ctGray      = 0
+	ctRGB       = 2
+	ctPalette   = 3
+	ctGrayAlpha = 4
+	ctRGBA      = 6
+)
+
+// ErrInterlaced is returned by NewPNGDecoder for interlaced images, which
+// can't be decoded from top to bottom.
+var ErrInterlaced = errors.New("interlaced png images can't be streamed")
+
+type pngDecoder struct {
+	r   *bufio.Reader
+	h   Header
+	y   int
+	crc hash.Hash32
+
+	depth   int
+	ctype   int
+	palette [][4]uint8
+	key     []uint16 // transparent colour of grey and RGB images
+
+	idat *idatReader
+	z    io.ReadCloser
+	bpp  int // bytes per pixel for filtering, at least 1
+	cur  []uint8
+	prev []uint8
+}
+
+// NewPNGDecoder will read the header of a non-interlaced PNG image and return
+// a decoder for its rows. Every colour type and bit depth is supported.
+// Palette and grey images are expanded to RGBA, and the alpha channel is
+// opaque unless the image has an alpha channel or transparency chunk.
+func NewPNGDecoder(r io.Reader) (Decoder, error) {
+	d := &pngDecoder{
+		r:   bufio.NewReader(r),
+		crc: crc32.NewIEEE(),
+	}
+
+	sig := make([]uint8, len(pngSignature))
+	if _, err := io.ReadFull(d.r, sig); err != nil {
+		return nil, err
+	}
+
+	if string(sig) != pngSignature {
+		return nil, errors.New("invalid png signature")
+	}
+
+	if err := d.readHeader(); err != nil {
+		return nil, err
+	}
+
+	z, err := zlib.NewReader(d.idat)
+	if err != nil {
+		return nil, err
+	}
+
+	d.z = z
+
+	return d, nil
+}
+
+// chunk will read the length and type of the next chunk, and start
+// calculating its checksum.
+func (d *pngDecoder) chunk() (uint32, string, error) {
+	var buf [8]uint8
+
+	if _, err := io.ReadFull(d.r, buf[:]); err != nil {
+		return 0, "", err
+	}
+
+	d.crc.Reset()
+	d.crc.Write(buf[4:])
+
+	return binary.BigEndian.Uint32(buf[:4]), string(buf[4:]), nil
+}
+
+// verify will read the checksum at the end of a chunk and compare it with the
+// checksum of the data which was read.
+func (d *pngDecoder) verify() error {
+	var buf [4]uint8
+
+	if _, err := io.ReadFull(d.r, buf[:]); err != nil {
+		return err
+	}
+
+	if binary.BigEndian.Uint32(buf[:]) != d.crc.Sum32() {
+		return errors.New("invalid png checksum")
+	}
+
+	return nil
+}
+
+// readHeader will read all chunks up to the first IDAT chunk.
+func (d *pngDecoder) readHeader() error {
+	for i := 0; ; i++ {
+		length, typ, err := d.chunk()
+		if err != nil {
+			return err
+		}
+
+		if i == 0 && typ != "IHDR" {
+			return errors.New("invalid png, missing IHDR chunk")
+		}
+
+		if typ == "IDAT" {
+			if d.ctype == ctPalette && d.palette == nil {
+				return errors.New("invalid png, missing PLTE chunk")
+			}
+
+			d.idat = &idatReader{d: d, remaining: length}
+
+			return nil
+		}
+
+		if length > 1<<24 {
+			return errors.New("invalid png, chunk too large")
+		}
+
+		data := make([]uint8, length)
+		if _, err := io.ReadFull(io.TeeReader(d.r, d.crc), data); err != nil {
+			return err
+		}
+
+		if err := d.verify(); err != nil {
+			return err
+		}
+
+		switch typ {
+		case "IHDR":
+			err = d.parseIHDR(data)
+		case "PLTE":
+			err = d.parsePLTE(data)
+		case "tRNS":
+			err = d.parseTRNS(data)
+		case "IEND":
+			err = errors.New("invalid png, missing IDAT chunk")
+		default:
+			if typ[0]&0x20 == 0 {
+				err = fmt.Errorf("unsupported critical png chunk %q", typ)
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (d *pngDecoder) parseIHDR(data []uint8) error {
+	if len(data) != 13 {
+		return errors.New("invalid png IHDR chunk")
+	}
+
+	width := binary.BigEndian.Uint32(data[0:4])
+	height := binary.BigEndian.Uint32(data[4:8])
+
+	if width == 0 || height == 0 || width > 1<<30 || height > 1<<30 {
+		return errors.New("invalid png dimensions")
+	}
+
+	d.depth, d.ctype = int(data[8]), int(data[9])
+
+	if data[10] != 0 || data[11] != 0 {
+		return errors.New("unsupported png compression or filter method")
+	}
+
+	if data[12] != 0 {
+		return ErrInterlaced
+	}
+
+	var channels int
+
+	switch {
+	case d.ctype == ctGray && (d.depth == 1 || d.depth == 2 || d.depth == 4 || d.depth == 8 || d.depth == 16):
+		channels = 1
+	case d.ctype == ctPalette && (d.depth == 1 || d.depth == 2 || d.depth == 4 || d.depth == 8):
+		channels = 1
+	case d.ctype == ctRGB && (d.depth == 8 || d.depth == 16):
+		channels = 3
+	case d.ctype == ctGrayAlpha && (d.depth == 8 || d.depth == 16):
+		channels = 2
+	case d.ctype == ctRGBA && (d.depth == 8 || d.depth == 16):
+		channels = 4
+	default:
+		return errors.New("unsupported png colour type or bit depth")
+	}
+
+	d.h = Header{
+		Width:  int(width),
+		Height: int(height),
+		Deep:   d.depth == 16,
+		Opaque: d.ctype != ctGrayAlpha && d.ctype != ctRGBA,
+	}
+
+	bits := channels * d.depth
+
+	d.bpp = (bits + 7) / 8
+	d.cur = make([]uint8, (bits*d.h.Width+7)/8)
+	d.prev = make([]uint8, len(d.cur))
+
+	return nil
+}
+
+func (d *pngDecoder) parsePLTE(data []uint8) error {
+	if len(data)%3 != 0 || len(data) > 256*3 {
+		return errors.New("invalid png PLTE chunk")
+	}
+
+	d.palette = make([][4]uint8, 256)
+
+	for i := 0; i < len(data)/3; i++ {
+		d.palette[i] = [4]uint8{data[3*i], data[3*i+1], data[3*i+2], 0xff}
+	}
+
+	// Out of range indices are black, like image/png.
+	for i := len(data) / 3; i < 256; i++ {
+		d.palette[i] = [4]uint8{0, 0, 0, 0xff}
+	}
+
+	return nil
+}
+
+func (d *pngDecoder) parseTRNS(data []uint8) error {
+	switch d.ctype {
+	case ctPalette:
+		if d.palette == nil || len(data) > 256 {
+			return errors.New("invalid png tRNS chunk")
+		}
+
+		for i, a := range data {
+			d.palette[i][3] = a
+		}
+	case ctGray:
+		if len(data) != 2 {
+			return errors.New("invalid png tRNS chunk")
+		}
+
+		d.key = []uint16{binary.BigEndian.Uint16(data)}
+	case ctRGB:
+		if len(data) != 6 {
+			return errors.New("invalid png tRNS chunk")
+		}
+
+		d.key = []uint16{binary.BigEndian.Uint16(data), binary.BigEndian.Uint16(data[2:]), binary.BigEndian.Uint16(data[4:])}
+	default:
+		return errors.New("invalid png tRNS chunk")
+	}
+
+	d.h.Opaque = false
+
+	return nil
+}
+
+// Header implements Decoder.
+func (d *pngDecoder) Header() Header {
+	return d.h
+}
+
+// Read implements Decoder.
+func (d *pngDecoder) Read(strip image.Image) error {
+	pix, stride, err := rows(strip, d.h)
+	if err != nil {
+		return err
+	}
+
+	n := strip.Bounds().Dy()
+	if d.y+n > d.h.Height {
+		return errors.New("too many rows read")
+	}
+
+	for i := 0; i < n; i++ {
+		if err := d.readRow(); err != nil {
+			return err
+		}
+
+		d.convert(pix[i*stride : i*stride+d.h.Width*bytesPerPixel(d.h)])
+		d.y++
+	}
+
+	return nil
+}
+
+// readRow will decompress the next row and reverse its filter.
+func (d *pngDecoder) readRow() error {
+	d.cur, d.prev = d.prev, d.cur
+
+	var filter [1]uint8
+
+	if _, err := io.ReadFull(d.z, filter[:]); err != nil {
+		return unexpected(err)
+	}
+
+	if _, err := io.ReadFull(d.z, d.cur); err != nil {
+		return unexpected(err)
+	}
+
+	cur, prev, bpp := d.cur, d.prev, d.bpp
+
+	// The row above the first row is all zeroes.
+	if d.y == 0 {
+		for i := range prev {
+			prev[i] = 0
+		}
+	}
+
+	switch filter[0] {
+	case 0:
+	case 1:
+		for i := bpp; i < len(cur); i++ {
+			cur[i] += cur[i-bpp]
+		}
+	case 2:
+		for i := range cur {
+			cur[i] += prev[i]
+		}
+	case 3:
+		for i := range cur {
+			var left int
+			if i >= bpp {
+				left = int(cur[i-bpp])
+			}
+
+			cur[i] += uint8((left + int(prev[i])) / 2)
+		}
+	case 4:
+		for i := range cur {
+			var left, upleft uint8
+			if i >= bpp {
+				left, upleft = cur[i-bpp], prev[i-bpp]
+			}
+
+			cur[i] += paeth(left, prev[i], upleft)
+		}
+	default:
+		return errors.New("invalid png filter type")
+	}
+
+	return nil
+}
+
+// convert will expand the current row to straight RGBA samples with 8 or 16
+// bits, in the layout of an *image.NRGBA or *image.NRGBA64.
+func (d *pngDecoder) convert(out []uint8) {
+	cur := d.cur
+
+	switch {
+	case d.depth == 16:
+		for x := 0; x < d.h.Width; x++ {
+			var s [4]uint16
+
+			switch d.ctype {
+			case ctGray:
+				v := binary.BigEndian.Uint16(cur[2*x:])
+				s = [4]uint16{v, v, v, 0xffff}
+
+				if d.key != nil && v == d.key[0] {
+					s[3] = 0
+				}
+			case ctGrayAlpha:
+				v := binary.BigEndian.Uint16(cur[4*x:])
+				s = [4]uint16{v, v, v, binary.BigEndian.Uint16(cur[4*x+2:])}
+			case ctRGB:
+				p := cur[6*x:]
+				s = [4]uint16{binary.BigEndian.Uint16(p), binary.BigEndian.Uint16(p[2:]), binary.BigEndian.Uint16(p[4:]), 0xffff}
+
+				if d.key != nil && s[0] == d.key[0] && s[1] == d.key[1] && s[2] == d.key[2] {
+					s[3] = 0
+				}
+			case ctRGBA:
+				p := cur[8*x:]
+				s = [4]uint16{binary.BigEndian.Uint16(p), binary.BigEndian.Uint16(p[2:]), binary.BigEndian.Uint16(p[4:]), binary.BigEndian.Uint16(p[6:])}
+			}
+
+			for i, v := range s {
+				binary.BigEndian.PutUint16(out[8*x+2*i:], v)
+			}
+		}
+	case d.ctype == ctRGB:
+		for x := 0; x < d.h.Width; x++ {
+			p := cur[3*x : 3*x+3]
+			o := out[4*x : 4*x+4]
+			o[0], o[1], o[2], o[3] = p[0], p[1], p[2], 0xff
+
+			if d.key != nil && uint16(p[0]) == d.key[0] && uint16(p[1]) == d.key[1] && uint16(p[2]) == d.key[2] {
+				o[3] = 0
+			}
+		}
+	case d.ctype == ctRGBA:
+		copy(out, cur[:4*d.h.Width])
+	case d.ctype == ctGrayAlpha:
+		for x := 0; x < d.h.Width; x++ {
+			v, a := cur[2*x], cur[2*x+1]
+			o := out[4*x : 4*x+4]
+			o[0], o[1], o[2], o[3] = v, v, v, a
+		}
+	default:
+		// Grey and palette images with up to 8 bits per pixel.
+		mask := uint8(1<<uint(d.depth) - 1)
+		perByte := 8 / d.depth
+
+		for x := 0; x < d.h.Width; x++ {
+			shift := uint(8 - d.depth*(x%perByte+1))
+			v := cur[x/perByte] >> shift & mask
+			o := out[4*x : 4*x+4]
+
+			if d.ctype == ctPalette {
+				c := d.palette[v]
+				o[0], o[1], o[2], o[3] = c[0], c[1], c[2], c[3]
+
+				continue
+			}
+
+			g := v * (0xff / mask)
+			o[0], o[1], o[2], o[3] = g, g, g, 0xff
+
+			if d.key != nil && uint16(v) == d.key[0] {
+				o[3] = 0
+			}
+		}
+	}
+}
+
+func paeth(a, b, c uint8) uint8 {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	default:
+		return c
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+
+	return v
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
+
+// idatReader reads the data of consecutive IDAT chunks.
+type idatReader struct {
+	d         *pngDecoder
+	remaining uint32
+	done      bool
+}
+
+func (r *idatReader) Read(p []uint8) (int, error) {
+	for r.remaining == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+
+		if err := r.d.verify(); err != nil {
+			return 0, err
+		}
+
+		length, typ, err := r.d.chunk()
+		if err != nil {
+			return 0, err
+		}
+
+		if typ != "IDAT" {
+			// Skip the rest of the file, the image data is complete.
+			r.done = true
+			_, _ = io.Copy(ioutil.Discard, r.d.r)
+
+			return 0, io.EOF
+		}
+
+		r.remaining = length
+	}
+
+	if uint32(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+
+	n, err := r.d.r.Read(p)
+	r.d.crc.Write(p[:n])
+	r.remaining -= uint32(n)
+
+	return n, unexpected(err)
+}
+
+type pngEncoder struct {
+	w   io.Writer
+	rw  *rowWriter
+	cw  *chunkWriter
+	z   *zlib.Writer
+	bpp int
+
+	prev     []uint8
+	cur      []uint8
+	filtered [5][]uint8
+}
+
+// NewPNGEncoder will write the header of a PNG image and return an encoder
+// for its rows. Images are written as RGB if they are opaque and RGBA
+// otherwise, with 8 or 16 bits per channel.
+func NewPNGEncoder(w io.Writer, h Header) (Encoder, error) {
+	if h.Width <= 0 || h.Height <= 0 {
+		return nil, errors.New("invalid png dimensions")
+	}
+
+	ctype, channels := uint8(ctRGBA), 4
+	if h.Opaque {
+		ctype, channels = ctRGB, 3
+	}
+
+	depth := uint8(8)
+	if h.Deep {
+		depth = 16
+	}
+
+	if _, err := io.WriteString(w, pngSignature); err != nil {
+		return nil, err
+	}
+
+	ihdr := make([]uint8, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], uint32(h.Width))
+	binary.BigEndian.PutUint32(ihdr[4:], uint32(h.Height))
+	ihdr[8], ihdr[9] = depth, ctype
+
+	if err := writeChunk(w, "IHDR", ihdr); err != nil {
+		return nil, err
+	}
+
+	e := &pngEncoder{
+		w:   w,
+		rw:  newRowWriter(h),
+		cw:  &chunkWriter{w: w},
+		bpp: channels * int(depth) / 8,
+	}
+
+	e.z = zlib.NewWriter(e.cw)
+
+	n := len(e.rw.buf)
+	e.prev = make([]uint8, n)
+	e.cur = make([]uint8, n)
+
+	for i := range e.filtered {
+		e.filtered[i] = make([]uint8, n+1)
+		e.filtered[i][0] = uint8(i)
+	}
+
+	return e, nil
+}
+
+// Write implements Encoder.
+func (e *pngEncoder) Write(strip image.Image) error {
+	return e.rw.each(strip, func(row []uint8) error {
+		e.prev, e.cur = e.cur, e.prev
+		copy(e.cur, row)
+
+		_, err := e.z.Write(e.filter())
+
+		return err
+	})
+}
+
+// filter will filter the current row with every filter type and return the
+// one with the smallest sum of absolute differences, like image/png.
+func (e *pngEncoder) filter() []uint8 {
+	cur, prev, bpp := e.cur, e.prev, e.bpp
+
+	if e.rw.y == 0 {
+		for i := range prev {
+			prev[i] = 0
+		}
+	}
+
+	copy(e.filtered[0][1:], cur)
+
+	sub, up, avg, pth := e.filtered[1][1:], e.filtered[2][1:], e.filtered[3][1:], e.filtered[4][1:]
+
+	for i := range cur {
+		var left, upleft uint8
+		if i >= bpp {
+			left, upleft = cur[i-bpp], prev[i-bpp]
+		}
+
+		sub[i] = cur[i] - left
+		up[i] = cur[i] - prev[i]
+		avg[i] = cur[i] - uint8((int(left)+int(prev[i]))/2)
+		pth[i] = cur[i] - paeth(left, prev[i], upleft)
+	}
+
+	best, min := 0, -1
+
+	for i, f := range e.filtered {
+		sum := 0
+
+		for _, v := range f[1:] {
+			sum += abs(int(int8(v)))
+		}
+
+		if min < 0 || sum < min {
+			best, min = i, sum
+		}
+	}
+
+	return e.filtered[best]
+}
+
+// Close implements Encoder.
+func (e *pngEncoder) Close() error {
+	if err := e.rw.done(); err != nil {
+		return err
+	}
+
+	if err := e.z.Close(); err != nil {
+		return err
+	}
+
+	if err := e.cw.flush(); err != nil {
+		return err
+	}
+
+	return writeChunk(e.w, "IEND", nil)
+}
+
+// chunkWriter splits compressed data into IDAT chunks.
+type chunkWriter struct {
+	w   io.Writer
+	buf []uint8
+}
+
+const maxChunk = 1 << 16
+
+func (c *chunkWriter) Write(p []uint8) (int, error) {
+	n := len(p)
+
+	for len(p) > 0 {
+		m := maxChunk - len(c.buf)
+		if m > len(p) {
+			m = len(p)
+		}
+
+		c.buf = append(c.buf, p[:m]...)
+		p = p[m:]
+
+		if len(c.buf) == maxChunk {
+			if err := c.flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return n, nil
+}
+
+func (c *chunkWriter) flush() error {
+	if len(c.buf) == 0 {
+		return nil
+	}
+
+	err := writeChunk(c.w, "IDAT", c.buf)
+	c.buf = c.buf[:0]
+
+	return err
+}
+
+func writeChunk(w io.Writer, typ string, data []uint8) error {
+	var header [8]uint8
+
+	binary.BigEndian.PutUint32(header[:4], uint32(len(data)))
+	copy(header[4:], typ)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+
+	var footer [4]uint8
+
+	binary.BigEndian.PutUint32(footer[:], crc.Sum32())
+
+	for _, b := range [][]uint8{header[:], data, footer[:]} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,232 @@
+package stream
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"strconv"
+)
+
+type ppmDecoder struct {
+	r      *bufio.Reader
+	h      Header
+	y      int
+	gray   bool
+	maxval int
+	buf    []uint8
+}
+
+// NewPPMDecoder will read the header of a binary PPM ("P6") or PGM ("P5")
+// image and return a decoder for its rows. Images with a maximum value above
+// 255 have 16 bits per channel, other maximum values are scaled to the full
+// range of 8 or 16 bits.
+func NewPPMDecoder(r io.Reader) (Decoder, error) {
+	d := &ppmDecoder{r: bufio.NewReader(r)}
+
+	magic, err := d.token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch magic {
+	case "P6":
+	case "P5":
+		d.gray = true
+	default:
+		return nil, errors.New("invalid ppm header")
+	}
+
+	var header [3]int
+
+	for i := range header {
+		s, err := d.token()
+		if err != nil {
+			return nil, err
+		}
+
+		header[i], err = strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ppm header: %w", err)
+		}
+	}
+
+	width, height, maxval := header[0], header[1], header[2]
+	if width <= 0 || height <= 0 || maxval <= 0 || maxval > 0xffff {
+		return nil, errors.New("invalid ppm header")
+	}
+
+	d.maxval = maxval
+	d.h = Header{
+		Width:  width,
+		Height: height,
+		Deep:   maxval > 0xff,
+		Opaque: true,
+	}
+
+	channels := 3
+	if d.gray {
+		channels = 1
+	}
+
+	depth := 1
+	if d.h.Deep {
+		depth = 2
+	}
+
+	d.buf = make([]uint8, width*channels*depth)
+
+	return d, nil
+}
+
+// token will read the next whitespace separated token of the header,
+// skipping comments. The single whitespace character after the token is
+// consumed as well, which ends the header after the maximum value.
+func (d *ppmDecoder) token() (string, error) {
+	var tok []byte
+
+	for {
+		c, err := d.r.ReadByte()
+		if err != nil {
+			return "", unexpected(err)
+		}
+
+		switch {
+		case c == '#' && len(tok) == 0:
+			if _, err := d.r.ReadString('\n'); err != nil {
+				return "", unexpected(err)
+			}
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if len(tok) > 0 {
+				return string(tok), nil
+			}
+		default:
+			tok = append(tok, c)
+		}
+	}
+}
+
+// Header implements Decoder.
+func (d *ppmDecoder) Header() Header {
+	return d.h
+}
+
+// Read implements Decoder.
+func (d *ppmDecoder) Read(strip image.Image) error {
+	pix, stride, err := rows(strip, d.h)
+	if err != nil {
+		return err
+	}
+
+	n := strip.Bounds().Dy()
+	if d.y+n > d.h.Height {
+		return errors.New("too many rows read")
+	}
+
+	for i := 0; i < n; i++ {
+		if _, err := io.ReadFull(d.r, d.buf); err != nil {
+			return unexpected(err)
+		}
+
+		d.convert(pix[i*stride:])
+		d.y++
+	}
+
+	return nil
+}
+
+// convert will expand the current row to RGBA samples in the layout of an
+// *image.NRGBA or *image.NRGBA64.
+func (d *ppmDecoder) convert(out []uint8) {
+	max := 0xff
+	if d.h.Deep {
+		max = 0xffff
+	}
+
+	sample := func(i int) int {
+		var v int
+
+		if d.h.Deep {
+			v = int(d.buf[2*i])<<8 | int(d.buf[2*i+1])
+		} else {
+			v = int(d.buf[i])
+		}
+
+		if v > d.maxval {
+			v = d.maxval
+		}
+
+		if d.maxval != max {
+			v = (v*max + d.maxval/2) / d.maxval
+		}
+
+		return v
+	}
+
+	for x := 0; x < d.h.Width; x++ {
+		var s [4]int
+
+		if d.gray {
+			v := sample(x)
+			s = [4]int{v, v, v, max}
+		} else {
+			s = [4]int{sample(3 * x), sample(3*x + 1), sample(3*x + 2), max}
+		}
+
+		for i, v := range s {
+			if d.h.Deep {
+				out[8*x+2*i], out[8*x+2*i+1] = uint8(v>>8), uint8(v)
+			} else {
+				out[4*x+i] = uint8(v)
+			}
+		}
+	}
+}
+
+type ppmEncoder struct {
+	w  *bufio.Writer
+	rw *rowWriter
+}
+
+// NewPPMEncoder will write the header of a binary PPM ("P6") image and return
+// an encoder for its rows. PPM images have no alpha channel, so the straight
+// colour of transparent pixels is written.
+func NewPPMEncoder(w io.Writer, h Header) (Encoder, error) {
+	if h.Width <= 0 || h.Height <= 0 {
+		return nil, errors.New("invalid ppm dimensions")
+	}
+
+	maxval := 0xff
+	if h.Deep {
+		maxval = 0xffff
+	}
+
+	e := &ppmEncoder{w: bufio.NewWriter(w)}
+
+	h.Opaque = true
+	e.rw = newRowWriter(h)
+
+	if _, err := fmt.Fprintf(e.w, "P6\n%d %d\n%d\n", h.Width, h.Height, maxval); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// Write implements Encoder.
+func (e *ppmEncoder) Write(strip image.Image) error {
+	return e.rw.each(strip, func(row []uint8) error {
+		_, err := e.w.Write(row)
+		return err
+	})
+}
+
+// Close implements Encoder.
+func (e *ppmEncoder) Close() error {
+	if err := e.rw.done(); err != nil {
+		return err
+	}
+
+	return e.w.Flush()
+}
@@ -0,0 +1,283 @@
+// Package stream grades images which are too large to keep in memory. Rows
+// are decoded, graded and encoded a strip at a time, so only a single strip of
+// pixels needs to be held in memory at once.
+package stream
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/wayneashleyberry/lut/pkg/lut"
+)
+
+// Header describes an image which is decoded or encoded a strip at a time.
+type Header struct {
+	Width  int
+	Height int
+	Deep   bool // 16 bits per channel instead of 8
+	Opaque bool // no alpha channel
+}
+
+// Decoder reads an image from top to bottom.
+type Decoder interface {
+	// Header will return the description of the image.
+	Header() Header
+	// Read will decode the next rows of the image into strip, which must be
+	// an *image.NRGBA for 8 bit images or an *image.NRGBA64 for 16 bit
+	// images, covering the full width of the image.
+	Read(strip image.Image) error
+}
+
+// Encoder writes an image from top to bottom.
+type Encoder interface {
+	// Write will encode the rows of strip as the next rows of the image.
+	Write(strip image.Image) error
+	// Close will finish the image once all rows have been written.
+	Close() error
+}
+
+// Apply will grade the image read from dec with fn and write it to enc,
+// taking the intensity multiplier into account. Strips are sized so that
+// their pixels use at most budget bytes, but always contain at least one
+// row. Options are passed to lut.ApplyTo for each strip, so Floyd-Steinberg
+// dithering only diffuses errors within a strip.
+func Apply(dec Decoder, enc Encoder, fn lut.Func, intensity float64, budget int, opts ...lut.Option) error {
+	h := dec.Header()
+
+	rows := 1
+	if h.Width > 0 {
+		rows = budget / (h.Width * bytesPerPixel(h))
+	}
+
+	if rows < 1 {
+		rows = 1
+	}
+
+	if rows > h.Height {
+		rows = h.Height
+	}
+
+	buf := make([]uint8, h.Width*bytesPerPixel(h)*rows)
+
+	for y := 0; y < h.Height; y += rows {
+		n := rows
+		if y+n > h.Height {
+			n = h.Height - y
+		}
+
+		strip := newStrip(h, buf, y, n)
+
+		if err := dec.Read(strip); err != nil {
+			return err
+		}
+
+		r := strip.Bounds()
+
+		if err := lut.ApplyTo(strip, r, strip, r.Min, fn, intensity, opts...); err != nil {
+			return err
+		}
+
+		if err := enc.Write(strip); err != nil {
+			return err
+		}
+	}
+
+	return enc.Close()
+}
+
+func bytesPerPixel(h Header) int {
+	if h.Deep {
+		return 8
+	}
+
+	return 4
+}
+
+// newStrip will create an image for n rows starting at y, using buf for its
+// pixels.
+func newStrip(h Header, buf []uint8, y, n int) draw.Image {
+	r := image.Rect(0, y, h.Width, y+n)
+	stride := h.Width * bytesPerPixel(h)
+	pix := buf[:stride*n]
+
+	if h.Deep {
+		return &image.NRGBA64{Pix: pix, Stride: stride, Rect: r}
+	}
+
+	return &image.NRGBA{Pix: pix, Stride: stride, Rect: r}
+}
+
+// rows will return the pixels of the rows of a strip, in the layout of an
+// *image.NRGBA or *image.NRGBA64, checking it has the expected width and
+// bit depth.
+func rows(strip image.Image, h Header) ([]uint8, int, error) {
+	var (
+		pix    []uint8
+		stride int
+	)
+
+	switch strip := strip.(type) {
+	case *image.NRGBA:
+		if h.Deep {
+			return nil, 0, errors.New("16 bit images must be read into an *image.NRGBA64")
+		}
+
+		pix, stride = strip.Pix, strip.Stride
+	case *image.NRGBA64:
+		if !h.Deep {
+			return nil, 0, errors.New("8 bit images must be read into an *image.NRGBA")
+		}
+
+		pix, stride = strip.Pix, strip.Stride
+	default:
+		return nil, 0, errors.New("strips must be an *image.NRGBA or *image.NRGBA64")
+	}
+
+	if strip.Bounds().Dx() != h.Width {
+		return nil, 0, errors.New("strips must cover the full width of the image")
+	}
+
+	return pix, stride, nil
+}
+
+// rowWriter converts the rows of a strip to 8 or 16 bit RGB or RGBA samples,
+// as written by the encoders.
+type rowWriter struct {
+	h   Header
+	y   int
+	buf []uint8
+}
+
+func newRowWriter(h Header) *rowWriter {
+	channels := 4
+	if h.Opaque {
+		channels = 3
+	}
+
+	depth := 1
+	if h.Deep {
+		depth = 2
+	}
+
+	return &rowWriter{h: h, buf: make([]uint8, h.Width*channels*depth)}
+}
+
+// each will call fn with the samples of every row of strip.
+func (w *rowWriter) each(strip image.Image, fn func(row []uint8) error) error {
+	b := strip.Bounds()
+
+	if b.Dx() != w.h.Width {
+		return errors.New("strips must cover the full width of the image")
+	}
+
+	if w.y+b.Dy() > w.h.Height {
+		return errors.New("too many rows written")
+	}
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		w.row(strip, y)
+
+		if err := fn(w.buf); err != nil {
+			return err
+		}
+
+		w.y++
+	}
+
+	return nil
+}
+
+// row will convert a single row of strip into buf.
+func (w *rowWriter) row(strip image.Image, y int) {
+	b := strip.Bounds()
+	out := w.buf
+
+	switch strip := strip.(type) {
+	case *image.NRGBA:
+		if !w.h.Deep {
+			pix := strip.Pix[strip.PixOffset(b.Min.X, y):]
+
+			if !w.h.Opaque {
+				copy(out, pix[:4*b.Dx()])
+				return
+			}
+
+			for x, j := 0, 0; x < b.Dx(); x, j = x+1, j+3 {
+				copy(out[j:j+3], pix[4*x:4*x+3])
+			}
+
+			return
+		}
+	case *image.NRGBA64:
+		if w.h.Deep {
+			pix := strip.Pix[strip.PixOffset(b.Min.X, y):]
+
+			if !w.h.Opaque {
+				copy(out, pix[:8*b.Dx()])
+				return
+			}
+
+			for x, j := 0, 0; x < b.Dx(); x, j = x+1, j+6 {
+				copy(out[j:j+6], pix[8*x:8*x+6])
+			}
+
+			return
+		}
+	}
+
+	j := 0
+
+	for x := b.Min.X; x < b.Max.X; x++ {
+		c := color.NRGBA64Model.Convert(strip.At(x, y)).(color.NRGBA64)
+
+		samples := [4]uint16{c.R, c.G, c.B, c.A}
+		n := 4
+
+		if w.h.Opaque {
+			n = 3
+		}
+
+		for _, s := range samples[:n] {
+			if w.h.Deep {
+				out[j], out[j+1] = uint8(s>>8), uint8(s)
+				j += 2
+			} else {
+				out[j] = uint8(s >> 8)
+				j++
+			}
+		}
+	}
+}
+
+// done will check that every row of the image has been written.
+func (w *rowWriter) done() error {
+	if w.y != w.h.Height {
+		return errors.New("not all rows of the image were written")
+	}
+
+	return nil
+}
+
+// Decode will read every row of an image into a single *image.NRGBA or
+// *image.NRGBA64.
+func Decode(dec Decoder) (image.Image, error) {
+	h := dec.Header()
+	img := newStrip(h, make([]uint8, h.Width*bytesPerPixel(h)*h.Height), 0, h.Height)
+
+	if err := dec.Read(img); err != nil {
+		return nil, err
+	}
+
+	return img, nil
+}
+
+// Encode will write every row of img and close the encoder.
+func Encode(enc Encoder, img image.Image) error {
+	if err := enc.Write(img); err != nil {
+		return err
+	}
+
+	return enc.Close()
+}
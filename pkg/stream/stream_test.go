@@ -0,0 +1,373 @@
+package stream
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"testing"
+
+	"github.com/wayneashleyberry/lut/pkg/lut"
+)
+
+// pattern will fill img with a pattern which exercises every png filter.
+func pattern(img interface {
+	image.Image
+	Set(x, y int, c color.Color)
+}) {
+	b := img.Bounds()
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			v := uint16(x*2731 + y*y*97)
+			img.Set(x, y, color.NRGBA64{v, 0xffff - v, uint16(x * y * 31), uint16(0xffff - x*y*11)})
+		}
+	}
+}
+
+func images() map[string]image.Image {
+	r := image.Rect(0, 0, 67, 41)
+
+	nrgba := image.NewNRGBA(r)
+	pattern(nrgba)
+
+	opaque := image.NewNRGBA(r)
+	pattern(opaque)
+
+	for i := 3; i < len(opaque.Pix); i += 4 {
+		opaque.Pix[i] = 0xff
+	}
+
+	nrgba64 := image.NewNRGBA64(r)
+	pattern(nrgba64)
+
+	opaque64 := image.NewRGBA64(r)
+	pattern(opaque64)
+
+	for i := 6; i < len(opaque64.Pix); i += 8 {
+		opaque64.Pix[i], opaque64.Pix[i+1] = 0xff, 0xff
+	}
+
+	gray := image.NewGray(r)
+	pattern(gray)
+
+	gray16 := image.NewGray16(r)
+	pattern(gray16)
+
+	imgs := map[string]image.Image{
+		"nrgba":    nrgba,
+		"opaque":   opaque,
+		"nrgba64":  nrgba64,
+		"opaque64": opaque64,
+		"gray":     gray,
+		"gray16":   gray16,
+	}
+
+	// Palettes of these sizes are written with 1, 2, 4 and 8 bits per pixel.
+	for _, n := range []int{2, 4, 16, 256} {
+		palette := make(color.Palette, n)
+		for i := range palette {
+			palette[i] = color.NRGBA{uint8(i * 7), uint8(255 - i), uint8(i * 3), uint8(255 - i%3*50)}
+		}
+
+		img := image.NewPaletted(r, palette)
+		for i := range img.Pix {
+			img.Pix[i] = uint8(i * 13 % n)
+		}
+
+		imgs["palette"+string(rune('0'+len(imgs)))] = img
+	}
+
+	return imgs
+}
+
+// compare will fail if two images have different bounds or colours.
+func compare(t *testing.T, got, want image.Image) {
+	t.Helper()
+
+	if got.Bounds() != want.Bounds() {
+		t.Fatalf("bounds = %v, want %v", got.Bounds(), want.Bounds())
+	}
+
+	b := want.Bounds()
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			g := color.NRGBA64Model.Convert(got.At(x, y))
+			w := color.NRGBA64Model.Convert(want.At(x, y))
+
+			if g != w {
+				t.Fatalf("at (%d, %d) = %v, want %v", x, y, g, w)
+			}
+		}
+	}
+}
+
+// readStrips will decode an image in strips of the given number of rows.
+func readStrips(t *testing.T, dec Decoder, n int) image.Image {
+	t.Helper()
+
+	h := dec.Header()
+	buf := make([]uint8, h.Width*bytesPerPixel(h)*h.Height)
+
+	for y := 0; y < h.Height; y += n {
+		rows := n
+		if y+rows > h.Height {
+			rows = h.Height - y
+		}
+
+		strip := newStrip(h, buf[y*h.Width*bytesPerPixel(h):], y, rows)
+
+		if err := dec.Read(strip); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return newStrip(h, buf, 0, h.Height)
+}
+
+func TestPNGDecoder(t *testing.T) {
+	for name, img := range images() {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			if err := png.Encode(&buf, img); err != nil {
+				t.Fatal(err)
+			}
+
+			dec, err := NewPNGDecoder(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want, err := png.Decode(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			compare(t, readStrips(t, dec, 7), want)
+		})
+	}
+}
+
+func TestPNGDecoderFile(t *testing.T) {
+	f, err := os.Open("../../testdata/images/transparent.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	dec, err := NewPNGDecoder(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := readStrips(t, dec, 64)
+
+	if _, err := f.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compare(t, got, want)
+}
+
+func TestPNGDecoderInterlaced(t *testing.T) {
+	var buf bytes.Buffer
+
+	buf.WriteString(pngSignature)
+
+	if err := writeChunk(&buf, "IHDR", []uint8{0, 0, 0, 1, 0, 0, 0, 1, 8, 2, 0, 0, 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewPNGDecoder(&buf); err != ErrInterlaced {
+		t.Errorf("NewPNGDecoder() error = %v, want %v", err, ErrInterlaced)
+	}
+}
+
+func TestPNGEncoder(t *testing.T) {
+	for name, img := range images() {
+		t.Run(name, func(t *testing.T) {
+			h := Header{
+				Width:  img.Bounds().Dx(),
+				Height: img.Bounds().Dy(),
+				Deep:   name == "nrgba64" || name == "opaque64" || name == "gray16",
+				Opaque: name == "opaque" || name == "opaque64" || name == "gray" || name == "gray16",
+			}
+
+			var buf bytes.Buffer
+
+			enc, err := NewPNGEncoder(&buf, h)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := Encode(enc, img); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := png.Decode(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !h.Deep {
+				// 8 bit encoding rounds 16 bit colours down.
+				want := image.NewNRGBA(img.Bounds())
+				for y := 0; y < h.Height; y++ {
+					for x := 0; x < h.Width; x++ {
+						c := color.NRGBA64Model.Convert(img.At(x, y)).(color.NRGBA64)
+						want.SetNRGBA(x, y, color.NRGBA{uint8(c.R >> 8), uint8(c.G >> 8), uint8(c.B >> 8), uint8(c.A >> 8)})
+					}
+				}
+
+				img = want
+			}
+
+			compare(t, got, img)
+		})
+	}
+
+	if enc, err := NewPNGEncoder(&bytes.Buffer{}, Header{Width: 1, Height: 2}); err != nil {
+		t.Fatal(err)
+	} else if err := Encode(enc, image.NewNRGBA(image.Rect(0, 0, 1, 1))); err == nil {
+		t.Error("Close() expected an error for a missing row")
+	}
+}
+
+func TestPPM(t *testing.T) {
+	for name, img := range images() {
+		if name != "opaque" && name != "opaque64" {
+			continue
+		}
+
+		t.Run(name, func(t *testing.T) {
+			h := Header{
+				Width:  img.Bounds().Dx(),
+				Height: img.Bounds().Dy(),
+				Deep:   name == "opaque64",
+				Opaque: true,
+			}
+
+			var buf bytes.Buffer
+
+			enc, err := NewPPMEncoder(&buf, h)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := Encode(enc, img); err != nil {
+				t.Fatal(err)
+			}
+
+			dec, err := NewPPMDecoder(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if dec.Header() != h {
+				t.Fatalf("Header() = %v, want %v", dec.Header(), h)
+			}
+
+			compare(t, readStrips(t, dec, 5), img)
+		})
+	}
+}
+
+func TestPPMDecoder(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want color.NRGBA64
+	}{
+		{"maxval 15", "P6 1 1 15\n\x0f\x00\x05", color.NRGBA64{0xffff, 0, 0x5555, 0xffff}},
+		{"comment", "P6\n# comment\n1 1\n255\n\x80\x40\x20", color.NRGBA64{0x8080, 0x4040, 0x2020, 0xffff}},
+		{"gray", "P5 1 1 255\n\x80", color.NRGBA64{0x8080, 0x8080, 0x8080, 0xffff}},
+		{"16 bit", "P6 1 1 65535\n\x12\x34\x56\x78\x9a\xbc", color.NRGBA64{0x1234, 0x5678, 0x9abc, 0xffff}},
+		{"maxval 1000", "P5 1 1 1000\n\x01\xf4", color.NRGBA64{0x8000, 0x8000, 0x8000, 0xffff}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec, err := NewPPMDecoder(bytes.NewReader([]byte(tt.in)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			img, err := Decode(dec)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := color.NRGBA64Model.Convert(img.At(0, 0)); got != tt.want {
+				t.Errorf("Decode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	for _, in := range []string{"P3 1 1 255\n", "P6 0 1 255\n", "P6 1 1 65536\n", "P6 1 1 255\n\x00"} {
+		dec, err := NewPPMDecoder(bytes.NewReader([]byte(in)))
+		if err == nil {
+			_, err = Decode(dec)
+		}
+
+		if err == nil {
+			t.Errorf("Decode(%q) expected an error", in)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	invert := func(r, g, b float64) (float64, float64, float64) {
+		return 1 - r, 1 - g, 1 - b
+	}
+
+	for _, name := range []string{"nrgba", "nrgba64"} {
+		t.Run(name, func(t *testing.T) {
+			img := images()[name]
+
+			var in bytes.Buffer
+
+			if err := png.Encode(&in, img); err != nil {
+				t.Fatal(err)
+			}
+
+			dec, err := NewPNGDecoder(&in)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var out bytes.Buffer
+
+			enc, err := NewPNGEncoder(&out, dec.Header())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Small enough for strips of three rows.
+			budget := 3 * img.Bounds().Dx() * bytesPerPixel(dec.Header())
+
+			if err := Apply(dec, enc, invert, 0.75, budget, lut.WithWorkers(2)); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := png.Decode(&out)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want, err := lut.Apply(img, invert, 0.75)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			compare(t, got, want)
+		})
+	}
+}
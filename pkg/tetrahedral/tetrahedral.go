@@ -0,0 +1,139 @@
+// Package tetrahedral implements tetrahedral interpolation
+package tetrahedral
+
+import (
+	"image"
+	"image/draw"
+
+	"github.com/wayneashleyberry/lut/pkg/colorcube"
+	"github.com/wayneashleyberry/lut/pkg/fixed"
+	"github.com/wayneashleyberry/lut/pkg/lut"
+)
+
+// Interpolate will apply color transformations to the provided image using
+// tetrahedral interpolation (taking the intensity multiplier into account).
+func Interpolate(src image.Image, cube colorcube.Cube, intensity float64, opts ...lut.Option) (image.Image, error) {
+	return lut.Apply(src, func(r, g, b float64) (float64, float64, float64) {
+		return Lookup(cube, r, g, b)
+	}, intensity, opts...)
+}
+
+// ApplyTo will apply color transformations to the rectangle r of dst using
+// tetrahedral interpolation, reading from src starting at sp (taking the
+// intensity multiplier into account). See lut.ApplyTo for details.
+func ApplyTo(dst draw.Image, r image.Rectangle, src image.Image, sp image.Point, cube colorcube.Cube, intensity float64, opts ...lut.Option) error {
+	return lut.ApplyTo(dst, r, src, sp, func(r, g, b float64) (float64, float64, float64) {
+		return Lookup(cube, r, g, b)
+	}, intensity, opts...)
+}
+
+// Lookup will return the colour at the given point in the domain of the cube.
+//
+// The cell surrounding the point is split into six tetrahedra which all share
+// the diagonal from the darkest to the brightest corner, the result is a
+// weighted sum of the four corners of the tetrahedron containing the point.
+func Lookup(cube colorcube.Cube, r, g, b float64) (float64, float64, float64) {
+	if r, g, b, ok := cube.Extrapolate(Lookup, r, g, b); ok {
+		return r, g, b
+	}
+
+	r0, r1, dr := cube.Cell(0, r)
+	g0, g1, dg := cube.Cell(1, g)
+	b0, b1, db := cube.Cell(2, b)
+
+	c000 := cube.Get(r0, g0, b0)
+	c111 := cube.Get(r1, g1, b1)
+
+	var (
+		c1, c2         []float64
+		w0, w1, w2, w3 float64
+	)
+
+	switch {
+	case dr > dg && dg > db:
+		c1, c2 = cube.Get(r1, g0, b0), cube.Get(r1, g1, b0)
+		w0, w1, w2, w3 = 1-dr, dr-dg, dg-db, db
+	case dr > dg && dr > db:
+		c1, c2 = cube.Get(r1, g0, b0), cube.Get(r1, g0, b1)
+		w0, w1, w2, w3 = 1-dr, dr-db, db-dg, dg
+	case dr > dg:
+		c1, c2 = cube.Get(r0, g0, b1), cube.Get(r1, g0, b1)
+		w0, w1, w2, w3 = 1-db, db-dr, dr-dg, dg
+	case db > dg:
+		c1, c2 = cube.Get(r0, g0, b1), cube.Get(r0, g1, b1)
+		w0, w1, w2, w3 = 1-db, db-dg, dg-dr, dr
+	case db > dr:
+		c1, c2 = cube.Get(r0, g1, b0), cube.Get(r0, g1, b1)
+		w0, w1, w2, w3 = 1-dg, dg-db, db-dr, dr
+	default:
+		c1, c2 = cube.Get(r0, g1, b0), cube.Get(r1, g1, b0)
+		w0, w1, w2, w3 = 1-dg, dg-dr, dr-db, db
+	}
+
+	return w0*c000[0] + w1*c1[0] + w2*c2[0] + w3*c111[0],
+		w0*c000[1] + w1*c1[1] + w2*c2[1] + w3*c111[1],
+		w0*c000[2] + w1*c1[2] + w2*c2[2] + w3*c111[2]
+}
+
+// Fixed is an integer tetrahedral kernel for use with lut.WithFixed.
+type Fixed struct {
+	lattice *fixed.Lattice
+}
+
+// NewFixed will create an integer tetrahedral kernel for the cube. Colours in
+// the cube are clamped to 0..1, see fixed.NewLattice.
+func NewFixed(cube colorcube.Cube) *Fixed {
+	return &Fixed{lattice: fixed.NewLattice(cube)}
+}
+
+// Map16 will return the colour at the given point, like Lookup but with 16 bit
+// channels and 16.16 fixed point arithmetic.
+func (f *Fixed) Map16(r, g, b uint16) (uint16, uint16, uint16) {
+	l := f.lattice
+
+	r0, r1, dr := l.Cell(0, r)
+	g0, g1, dg := l.Cell(1, g)
+	b0, b1, db := l.Cell(2, b)
+
+	i000 := l.Index(r0, g0, b0)
+	i111 := l.Index(r1, g1, b1)
+
+	var (
+		i1, i2         int
+		w0, w1, w2, w3 int32
+	)
+
+	switch {
+	case dr > dg && dg > db:
+		i1, i2 = l.Index(r1, g0, b0), l.Index(r1, g1, b0)
+		w0, w1, w2, w3 = fixed.One-dr, dr-dg, dg-db, db
+	case dr > dg && dr > db:
+		i1, i2 = l.Index(r1, g0, b0), l.Index(r1, g0, b1)
+		w0, w1, w2, w3 = fixed.One-dr, dr-db, db-dg, dg
+	case dr > dg:
+		i1, i2 = l.Index(r0, g0, b1), l.Index(r1, g0, b1)
+		w0, w1, w2, w3 = fixed.One-db, db-dr, dr-dg, dg
+	case db > dg:
+		i1, i2 = l.Index(r0, g0, b1), l.Index(r0, g1, b1)
+		w0, w1, w2, w3 = fixed.One-db, db-dg, dg-dr, dr
+	case db > dr:
+		i1, i2 = l.Index(r0, g1, b0), l.Index(r0, g1, b1)
+		w0, w1, w2, w3 = fixed.One-dg, dg-db, db-dr, dr
+	default:
+		i1, i2 = l.Index(r0, g1, b0), l.Index(r1, g1, b0)
+		w0, w1, w2, w3 = fixed.One-dg, dg-dr, dr-db, db
+	}
+
+	var out [3]uint16
+
+	for ch := range out {
+		v := int64(w0)*int64(l.Data[i000+ch]) +
+			int64(w1)*int64(l.Data[i1+ch]) +
+			int64(w2)*int64(l.Data[i2+ch]) +
+			int64(w3)*int64(l.Data[i111+ch])
+
+		out[ch] = fixed.Clamp((v + fixed.One/2) >> 16)
+	}
+
+	return out[0], out[1], out[2]
+}
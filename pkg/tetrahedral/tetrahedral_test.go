@@ -0,0 +1,63 @@
+package tetrahedral
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/wayneashleyberry/lut/pkg/interptest"
+	"github.com/wayneashleyberry/lut/pkg/lut"
+	"github.com/wayneashleyberry/lut/pkg/trilinear"
+)
+
+func TestLookup(t *testing.T) {
+	interptest.Check(t, Lookup, trilinear.Lookup)
+}
+
+func TestImages(t *testing.T) {
+	interptest.CheckImages(t, interptest.Filter(t, "DU04.cube"), Lookup, trilinear.Lookup, 2)
+}
+
+func TestInterpolate(t *testing.T) {
+	src := interptest.Gradient(256, 64)
+
+	for _, fn := range []lut.Func{interptest.Identity, interptest.CrossTalk} {
+		cube := interptest.Cube(33, fn)
+
+		got, err := Interpolate(src, cube, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want, err := trilinear.Interpolate(src, cube, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		bounds := src.Bounds()
+
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				a := got.At(x, y).(color.NRGBA)
+				b := want.At(x, y).(color.NRGBA)
+
+				if diff(a.R, b.R) > 1 || diff(a.G, b.G) > 1 || diff(a.B, b.B) > 1 {
+					t.Fatalf("Interpolate() at (%d, %d) = %v, trilinear %v", x, y, a, b)
+				}
+			}
+		}
+	}
+}
+
+func diff(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+
+	return int(b - a)
+}
+
+func TestFixed(t *testing.T) {
+	cube := interptest.Filter(t, "DU04.cube")
+
+	interptest.CheckFixed(t, cube, Lookup, NewFixed(cube))
+}
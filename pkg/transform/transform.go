@@ -0,0 +1,119 @@
+// Package transform implements colour transforms which can be combined and
+// baked into a colour cube of any size. Cubes, curves, matrices and plain
+// functions all share the Transform interface, so a look can be built from
+// several of them and then written out with cubelut or imagelut.
+package transform
+
+import (
+	"math"
+
+	"github.com/wayneashleyberry/lut/pkg/colorcube"
+)
+
+// Transform maps a colour to a new colour. It is implemented by lut.Func,
+// colorcube.Transform and the types in this package.
+type Transform interface {
+	Map(r, g, b float64) (float64, float64, float64)
+}
+
+// Curve is a 1D lookup table, sampled at evenly spaced points from 0 to 1.
+// Values between the samples are linearly interpolated and values outside of
+// 0..1 are clamped to the first and last sample.
+type Curve []float64
+
+// At will return the value of the curve at v. An empty curve returns v.
+func (c Curve) At(v float64) float64 {
+	switch {
+	case len(c) == 0:
+		return v
+	case len(c) == 1 || v <= 0 || math.IsNaN(v):
+		return c[0]
+	case v >= 1:
+		return c[len(c)-1]
+	}
+
+	f := v * float64(len(c)-1)
+	i := int(f)
+
+	if i >= len(c)-1 {
+		return c[len(c)-1]
+	}
+
+	d := f - float64(i)
+
+	return c[i]*(1-d) + c[i+1]*d
+}
+
+// Curves applies a separate curve to each channel, in red, green and blue
+// order. Channels with an empty curve are left unchanged.
+type Curves [3]Curve
+
+// Map implements Transform.
+func (c Curves) Map(r, g, b float64) (float64, float64, float64) {
+	return c[0].At(r), c[1].At(g), c[2].At(b)
+}
+
+// Matrix is a 3x3 colour matrix. Each row produces one output channel, so the
+// new red value is m[0][0]*r + m[0][1]*g + m[0][2]*b.
+type Matrix [3][3]float64
+
+// Identity is the matrix which leaves colours unchanged.
+var Identity = Matrix{
+	{1, 0, 0},
+	{0, 1, 0},
+	{0, 0, 1},
+}
+
+// Map implements Transform.
+func (m Matrix) Map(r, g, b float64) (float64, float64, float64) {
+	return m[0][0]*r + m[0][1]*g + m[0][2]*b,
+		m[1][0]*r + m[1][1]*g + m[1][2]*b,
+		m[2][0]*r + m[2][1]*g + m[2][2]*b
+}
+
+// Chain applies several transforms one after another, starting with the
+// first. An empty chain leaves colours unchanged.
+type Chain []Transform
+
+// Map implements Transform.
+func (c Chain) Map(r, g, b float64) (float64, float64, float64) {
+	for _, t := range c {
+		r, g, b = t.Map(r, g, b)
+	}
+
+	return r, g, b
+}
+
+// Bake will sample t at every point of a new cube with the given size and
+// domain, see colorcube.New. A nil domain defaults to 0..1. Colours are
+// stored as returned by t, they are not clamped.
+func Bake(t Transform, size int, dmin, dmax []float64) colorcube.Cube {
+	cube := colorcube.New(size, dmin, dmax)
+
+	// Lattice positions along each axis, in the domain of the cube.
+	var pos [3][]float64
+
+	for axis := range pos {
+		min, max := cube.Domain(axis)
+		pos[axis] = make([]float64, size)
+
+		for i := range pos[axis] {
+			if size > 1 {
+				pos[axis][i] = min + (max-min)*float64(i)/float64(size-1)
+			} else {
+				pos[axis][i] = min
+			}
+		}
+	}
+
+	for z := 0; z < size; z++ {
+		for y := 0; y < size; y++ {
+			for x := 0; x < size; x++ {
+				rgb := cube.Get(x, y, z)
+				rgb[0], rgb[1], rgb[2] = t.Map(pos[0][x], pos[1][y], pos[2][z])
+			}
+		}
+	}
+
+	return cube
+}
@@ -0,0 +1,100 @@
+package transform
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/wayneashleyberry/lut/pkg/colorcube"
+)
+
+type fn func(r, g, b float64) (float64, float64, float64)
+
+func (f fn) Map(r, g, b float64) (float64, float64, float64) {
+	return f(r, g, b)
+}
+
+func TestCurve_At(t *testing.T) {
+	tests := []struct {
+		name  string
+		curve Curve
+		in    float64
+		want  float64
+	}{
+		{"empty", nil, 0.3, 0.3},
+		{"single", Curve{0.5}, 0.3, 0.5},
+		{"first", Curve{0.2, 0.4, 1}, 0, 0.2},
+		{"last", Curve{0.2, 0.4, 1}, 1, 1},
+		{"between", Curve{0.2, 0.4, 1}, 0.75, 0.7},
+		{"below", Curve{0.2, 0.4, 1}, -1, 0.2},
+		{"above", Curve{0.2, 0.4, 1}, 2, 1},
+		{"nan", Curve{0.2, 0.4, 1}, math.NaN(), 0.2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.curve.At(tt.in); math.Abs(got-tt.want) > 1e-12 {
+				t.Errorf("Curve.At(%v) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMap(t *testing.T) {
+	invert := Curve{1, 0}
+	swap := Matrix{
+		{0, 0, 1},
+		{0, 1, 0},
+		{1, 0, 0},
+	}
+
+	tests := []struct {
+		name string
+		t    Transform
+		want [3]float64
+	}{
+		{"curves", Curves{invert, nil, Curve{0, 0.5}}, [3]float64{0.8, 0.5, 0.4}},
+		{"identity", Identity, [3]float64{0.2, 0.5, 0.8}},
+		{"matrix", swap, [3]float64{0.8, 0.5, 0.2}},
+		{"empty chain", Chain{}, [3]float64{0.2, 0.5, 0.8}},
+		{"chain", Chain{swap, Curves{invert}}, [3]float64{0.2, 0.5, 0.2}},
+		{"func", fn(func(r, g, b float64) (float64, float64, float64) {
+			return b, g, r
+		}), [3]float64{0.8, 0.5, 0.2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, g, b := tt.t.Map(0.2, 0.5, 0.8)
+
+			for i, v := range [3]float64{r, g, b} {
+				if math.Abs(v-tt.want[i]) > 1e-12 {
+					t.Fatalf("Map() = %v, %v, %v, want %v", r, g, b, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestBake(t *testing.T) {
+	cube := Bake(Identity, 3, []float64{0, 0, -1}, []float64{1, 2, 1})
+
+	if cube.Size != 3 || cube.Len() != 27 {
+		t.Fatalf("Bake() has size %d and %d points", cube.Size, cube.Len())
+	}
+
+	if got, want := cube.Get(1, 2, 0), []float64{0.5, 2, -1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Bake() at (1, 2, 0) = %v, want %v", got, want)
+	}
+
+	// Baking a cube at its own size reproduces it.
+	again := Bake(colorcube.Transform{Cube: cube}, 3, cube.DomainMin, cube.DomainMax)
+
+	if !reflect.DeepEqual(again.Data, cube.Data) {
+		t.Errorf("Bake() of a cube = %v, want %v", again.Data, cube.Data)
+	}
+
+	if got := Bake(Identity, 1, nil, nil).Get(0, 0, 0); !reflect.DeepEqual(got, []float64{0, 0, 0}) {
+		t.Errorf("Bake() with size 1 = %v, want [0 0 0]", got)
+	}
+}
@@ -0,0 +1,199 @@
+// Package tricubic implements smooth tricubic interpolation, using either
+// Catmull-Rom splines which pass through every lattice point or cubic
+// B-splines which trade some accuracy for a smoother result.
+package tricubic
+
+import (
+	"image"
+	"image/draw"
+	"math"
+
+	"github.com/wayneashleyberry/lut/pkg/colorcube"
+	"github.com/wayneashleyberry/lut/pkg/lut"
+)
+
+// kernel returns the weights of the four lattice points surrounding a value,
+// t is the position of the value between the second and third point.
+type kernel func(t float64) [4]float64
+
+func catmullRom(t float64) [4]float64 {
+	t2 := t * t
+	t3 := t2 * t
+
+	return [4]float64{
+		(-t3 + 2*t2 - t) / 2,
+		(3*t3 - 5*t2 + 2) / 2,
+		(-3*t3 + 4*t2 + t) / 2,
+		(t3 - t2) / 2,
+	}
+}
+
+func bSpline(t float64) [4]float64 {
+	t2 := t * t
+	t3 := t2 * t
+
+	return [4]float64{
+		(1 - t) * (1 - t) * (1 - t) / 6,
+		(3*t3 - 6*t2 + 4) / 6,
+		(-3*t3 + 3*t2 + 3*t + 1) / 6,
+		t3 / 6,
+	}
+}
+
+// Interpolate will apply color transformations to the provided image using
+// Catmull-Rom tricubic interpolation (taking the intensity multiplier into
+// account).
+func Interpolate(src image.Image, cube colorcube.Cube, intensity float64, opts ...lut.Option) (image.Image, error) {
+	return lut.Apply(src, func(r, g, b float64) (float64, float64, float64) {
+		return CatmullRom(cube, r, g, b)
+	}, intensity, opts...)
+}
+
+// ApplyTo will apply color transformations to the rectangle r of dst using
+// Catmull-Rom tricubic interpolation, reading from src starting at sp (taking
+// the intensity multiplier into account). See lut.ApplyTo for details.
+func ApplyTo(dst draw.Image, r image.Rectangle, src image.Image, sp image.Point, cube colorcube.Cube, intensity float64, opts ...lut.Option) error {
+	return lut.ApplyTo(dst, r, src, sp, func(r, g, b float64) (float64, float64, float64) {
+		return CatmullRom(cube, r, g, b)
+	}, intensity, opts...)
+}
+
+// InterpolateBSpline will apply color transformations to the provided image
+// using B-spline tricubic interpolation (taking the intensity multiplier into
+// account).
+func InterpolateBSpline(src image.Image, cube colorcube.Cube, intensity float64, opts ...lut.Option) (image.Image, error) {
+	return lut.Apply(src, func(r, g, b float64) (float64, float64, float64) {
+		return BSpline(cube, r, g, b)
+	}, intensity, opts...)
+}
+
+// ApplyBSplineTo will apply color transformations to the rectangle r of dst
+// using B-spline tricubic interpolation, reading from src starting at sp
+// (taking the intensity multiplier into account). See lut.ApplyTo for details.
+func ApplyBSplineTo(dst draw.Image, r image.Rectangle, src image.Image, sp image.Point, cube colorcube.Cube, intensity float64, opts ...lut.Option) error {
+	return lut.ApplyTo(dst, r, src, sp, func(r, g, b float64) (float64, float64, float64) {
+		return BSpline(cube, r, g, b)
+	}, intensity, opts...)
+}
+
+// CatmullRom will return the colour at the given point in the domain of the
+// cube using Catmull-Rom splines. Every channel is clamped to the values of
+// the lattice points which contribute to it, so hard edges in a LUT don't
+// overshoot.
+func CatmullRom(cube colorcube.Cube, r, g, b float64) (float64, float64, float64) {
+	if r, g, b, ok := cube.Extrapolate(CatmullRom, r, g, b); ok {
+		return r, g, b
+	}
+
+	return lookup(cube, catmullRom, r, g, b)
+}
+
+// BSpline will return the colour at the given point in the domain of the cube
+// using cubic B-splines. B-splines approximate the lattice rather than passing
+// through it, so small details in a LUT are softened.
+func BSpline(cube colorcube.Cube, r, g, b float64) (float64, float64, float64) {
+	if r, g, b, ok := cube.Extrapolate(BSpline, r, g, b); ok {
+		return r, g, b
+	}
+
+	return lookup(cube, bSpline, r, g, b)
+}
+
+func lookup(cube colorcube.Cube, k kernel, r, g, b float64) (float64, float64, float64) {
+	if cube.Size < 2 {
+		c := cube.Get(0, 0, 0)
+		return c[0], c[1], c[2]
+	}
+
+	tr := taps(cube, k, 0, r)
+	tg := taps(cube, k, 1, g)
+	tb := taps(cube, k, 2, b)
+
+	var out, lo, hi [3]float64
+
+	for i := range lo {
+		lo[i], hi[i] = math.Inf(1), math.Inf(-1)
+	}
+
+	for _, z := range tb {
+		if z.w == 0 {
+			continue
+		}
+
+		for _, y := range tg {
+			if y.w == 0 {
+				continue
+			}
+
+			for _, x := range tr {
+				if x.w == 0 {
+					continue
+				}
+
+				w := x.w * y.w * z.w
+				c := cube.Get(x.i, y.i, z.i)
+
+				out[0] += w * c[0]
+				out[1] += w * c[1]
+				out[2] += w * c[2]
+
+				for i := range lo {
+					lo[i], hi[i] = math.Min(lo[i], c[i]), math.Max(hi[i], c[i])
+				}
+			}
+		}
+	}
+
+	for i := range out {
+		out[i] = clamp(out[i], lo[i], hi[i])
+	}
+
+	return out[0], out[1], out[2]
+}
+
+// tap is a lattice index along one axis, along with its weight.
+type tap struct {
+	i int
+	w float64
+}
+
+// taps will return the four lattice points along the given axis which
+// contribute to the value v. Points which fall outside of the cube are
+// extrapolated linearly from the two nearest points on the edge, so their
+// weight is folded into those points instead.
+func taps(cube colorcube.Cube, k kernel, axis int, v float64) [4]tap {
+	i0, _, d := cube.Cell(axis, v)
+	w := k(d)
+
+	t := [4]tap{
+		{i0 - 1, w[0]},
+		{i0, w[1]},
+		{i0 + 1, w[2]},
+		{i0 + 2, w[3]},
+	}
+
+	if t[0].i < 0 {
+		t[1].w += 2 * t[0].w
+		t[2].w -= t[0].w
+		t[0] = tap{}
+	}
+
+	if t[3].i >= cube.Size {
+		t[2].w += 2 * t[3].w
+		t[1].w -= t[3].w
+		t[3] = tap{}
+	}
+
+	return t
+}
+
+func clamp(v, min, max float64) float64 {
+	switch {
+	case v < min:
+		return min
+	case v > max:
+		return max
+	default:
+		return v
+	}
+}
@@ -0,0 +1,102 @@
+package tricubic
+
+import (
+	"math"
+	"testing"
+
+	"github.com/wayneashleyberry/lut/pkg/colorcube"
+	"github.com/wayneashleyberry/lut/pkg/interptest"
+	"github.com/wayneashleyberry/lut/pkg/lut"
+	"github.com/wayneashleyberry/lut/pkg/trilinear"
+)
+
+func TestLookup(t *testing.T) {
+	interpolators := []struct {
+		name   string
+		interp colorcube.Interpolator
+	}{
+		{"catmull-rom", CatmullRom},
+		{"b-spline", BSpline},
+	}
+
+	funcs := []struct {
+		name string
+		fn   lut.Func
+	}{
+		{"identity", interptest.Identity},
+		{"gain", interptest.Gain(0.8, 1, 0.5)},
+		{"matrix", interptest.CrossTalk},
+	}
+
+	for _, i := range interpolators {
+		for _, f := range funcs {
+			t.Run(i.name+"/"+f.name, func(t *testing.T) {
+				for _, size := range []int{2, 9, 17} {
+					res := interptest.Measure(i.interp, size, f.fn, 23)
+					if res.Max > 1e-9 {
+						t.Errorf("size %d max error = %v, mean error = %v", size, res.Max, res.Mean)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestCatmullRomSmallCube(t *testing.T) {
+	fn := interptest.Gamma(2.2)
+
+	got := interptest.Measure(CatmullRom, 9, fn, 23)
+	want := interptest.Measure(trilinear.Lookup, 9, fn, 23)
+
+	if got.Mean >= want.Mean {
+		t.Errorf("CatmullRom() mean error = %v, trilinear %v", got.Mean, want.Mean)
+	}
+}
+
+func TestCatmullRomClamp(t *testing.T) {
+	// A hard edge in the middle of the cube makes Catmull-Rom overshoot.
+	step := func(r, g, b float64) (float64, float64, float64) {
+		if r < 0.5 {
+			return 0, 0, 0
+		}
+
+		return 1, 1, 1
+	}
+
+	cube := interptest.Cube(5, step)
+
+	for _, v := range []float64{0.3, 0.45, 0.55, 0.7, 0.9} {
+		r, g, b := CatmullRom(cube, v, 0.5, 0.5)
+
+		for _, c := range []float64{r, g, b} {
+			if c < 0 || c > 1 {
+				t.Errorf("CatmullRom(%v) = %v, %v, %v, want values between 0 and 1", v, r, g, b)
+			}
+		}
+	}
+}
+
+func TestCatmullRomRange(t *testing.T) {
+	// Clamping applies to the lattice values, not the domain of the cube.
+	cube := interptest.Cube(9, interptest.Gain(2, 2, 2))
+
+	for name, interp := range map[string]colorcube.Interpolator{"CatmullRom": CatmullRom, "BSpline": BSpline} {
+		r, g, b := interp(cube, 0.8, 0.8, 0.8)
+
+		for _, c := range []float64{r, g, b} {
+			if math.Abs(c-1.6) > 1e-9 {
+				t.Errorf("%s(0.8) = %v, %v, %v, want 1.6", name, r, g, b)
+			}
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	t.Run("catmull-rom", func(t *testing.T) {
+		interptest.Check(t, CatmullRom, trilinear.Lookup)
+	})
+
+	t.Run("b-spline", func(t *testing.T) {
+		interptest.Check(t, BSpline, trilinear.Lookup)
+	})
+}
@@ -2,76 +2,59 @@
 package trilinear
 
 import (
-	"errors"
 	"image"
-	"image/color"
-	"math"
+	"image/draw"
 
 	"github.com/wayneashleyberry/lut/pkg/colorcube"
-	"github.com/wayneashleyberry/lut/pkg/parallel"
+	"github.com/wayneashleyberry/lut/pkg/fixed"
+	"github.com/wayneashleyberry/lut/pkg/lut"
 )
 
-// bits per channel (we're assuming 8 bits).
-const bpc = 0xff
-
 // Interpolate will apply color transformations to the provided image using
 // trilinear interpolation (taking the intensity multiplier into account).
-func Interpolate(src image.Image, cube colorcube.Cube, intensity float64) (image.Image, error) {
-	if intensity < 0 || intensity > 1 {
-		return src, errors.New("intensity must be between 0 and 1")
-	}
+func Interpolate(src image.Image, cube colorcube.Cube, intensity float64, opts ...lut.Option) (image.Image, error) {
+	return lut.Apply(src, func(r, g, b float64) (float64, float64, float64) {
+		return Lookup(cube, r, g, b)
+	}, intensity, opts...)
+}
 
-	bounds := src.Bounds()
-
-	out := image.NewNRGBA(image.Rectangle{
-		image.Point{0, 0},
-		image.Point{bounds.Max.X, bounds.Max.Y},
-	})
-
-	k := (float64(cube.Size) - 1) / bpc
-
-	space := &image.NRGBA{}
-	model := space.ColorModel()
-
-	dKR := cube.DomainMax[0] - cube.DomainMin[0]
-	dKG := cube.DomainMax[1] - cube.DomainMin[1]
-	dKB := cube.DomainMax[2] - cube.DomainMin[2]
-
-	width, height := bounds.Dx(), bounds.Dy()
-	parallel.Line(height, func(start, end int) {
-		for y := start; y < end; y++ {
-			for x := 0; x < width; x++ {
-				px := src.At(x, y)
-				c := model.Convert(px).(color.NRGBA)
-
-				rgb := getFromRGBTrilinear(
-					int(c.R),
-					int(c.G),
-					int(c.B),
-					cube.Size,
-					k,
-					cube,
-				)
-
-				o := color.NRGBA{}
-				o.R = uint8(float64(c.R)*(1-intensity) + float64(toIntCh(rgb[0]*dKR))*intensity)
-				o.G = uint8(float64(c.G)*(1-intensity) + float64(toIntCh(rgb[1]*dKG))*intensity)
-				o.B = uint8(float64(c.B)*(1-intensity) + float64(toIntCh(rgb[2]*dKB))*intensity)
-				o.A = c.A
-
-				out.Set(x, y, o)
-			}
-		}
-	})
-
-	return out, nil
+// ApplyTo will apply color transformations to the rectangle r of dst using
+// trilinear interpolation, reading from src starting at sp (taking the
+// intensity multiplier into account). See lut.ApplyTo for details.
+func ApplyTo(dst draw.Image, r image.Rectangle, src image.Image, sp image.Point, cube colorcube.Cube, intensity float64, opts ...lut.Option) error {
+	return lut.ApplyTo(dst, r, src, sp, func(r, g, b float64) (float64, float64, float64) {
+		return Lookup(cube, r, g, b)
+	}, intensity, opts...)
 }
 
-func trilerp(x, y, z, c000, c001, c010, c011, c100, c101, c110, c111, x0, x1, y0, y1, z0, z1 float64) float64 {
-	xd := (x - x0) / (x1 - x0)
-	yd := (y - y0) / (y1 - y0)
-	zd := (z - z0) / (z1 - z0)
+// Lookup will return the colour at the given point in the domain of the cube,
+// blending the eight corners of the cell which encloses the point.
+func Lookup(cube colorcube.Cube, r, g, b float64) (float64, float64, float64) {
+	if r, g, b, ok := cube.Extrapolate(Lookup, r, g, b); ok {
+		return r, g, b
+	}
 
+	r0, r1, dr := cube.Cell(0, r)
+	g0, g1, dg := cube.Cell(1, g)
+	b0, b1, db := cube.Cell(2, b)
+
+	c000 := cube.Get(r0, g0, b0)
+	c001 := cube.Get(r0, g0, b1)
+	c010 := cube.Get(r0, g1, b0)
+	c011 := cube.Get(r0, g1, b1)
+	c100 := cube.Get(r1, g0, b0)
+	c101 := cube.Get(r1, g0, b1)
+	c110 := cube.Get(r1, g1, b0)
+	c111 := cube.Get(r1, g1, b1)
+
+	return trilerp(dr, dg, db, c000[0], c001[0], c010[0], c011[0], c100[0], c101[0], c110[0], c111[0]),
+		trilerp(dr, dg, db, c000[1], c001[1], c010[1], c011[1], c100[1], c101[1], c110[1], c111[1]),
+		trilerp(dr, dg, db, c000[2], c001[2], c010[2], c011[2], c100[2], c101[2], c110[2], c111[2])
+}
+
+// trilerp blends the corners of a unit cube, xd, yd and zd are the position
+// of the point inside the cube.
+func trilerp(xd, yd, zd, c000, c001, c010, c011, c100, c101, c110, c111 float64) float64 {
 	c00 := c000*(1.0-xd) + c100*xd
 	c01 := c001*(1.0-xd) + c101*xd
 	c10 := c010*(1.0-xd) + c110*xd
@@ -85,90 +68,48 @@ func trilerp(x, y, z, c000, c001, c010, c011, c100, c101, c110, c111, x0, x1, y0
 	return c
 }
 
-func clampToChannelSize(x int) int {
-	switch {
-	case x <= 0:
-		return 0
-	case x >= bpc:
-		return bpc
-	default:
-		return x
-	}
+// Fixed is an integer trilinear kernel for use with lut.WithFixed.
+type Fixed struct {
+	lattice *fixed.Lattice
 }
 
-func toIntCh(x float64) int {
-	return clampToChannelSize(int(math.Floor(x * float64(bpc))))
+// NewFixed will create an integer trilinear kernel for the cube. Colours in the
+// cube are clamped to 0..1, see fixed.NewLattice.
+func NewFixed(cube colorcube.Cube) *Fixed {
+	return &Fixed{lattice: fixed.NewLattice(cube)}
 }
 
-func getFromRGBTrilinear(r, g, b, size int, k float64, cube colorcube.Cube) []float64 {
-	iR := float64(r) * k
+// Map16 will return the colour at the given point, like Lookup but with 16 bit
+// channels and 16.16 fixed point arithmetic.
+func (f *Fixed) Map16(r, g, b uint16) (uint16, uint16, uint16) {
+	l := f.lattice
 
-	var fR1 int
-	if iR >= float64(size)-1 {
-		fR1 = clampToChannelSize(size - 1)
-	} else {
-		fR1 = clampToChannelSize(int(math.Floor(iR + 1)))
-	}
+	r0, r1, dr := l.Cell(0, r)
+	g0, g1, dg := l.Cell(1, g)
+	b0, b1, db := l.Cell(2, b)
 
-	var fR0 int
-	if iR > 0 {
-		fR0 = clampToChannelSize(int(math.Floor(iR - 1)))
-	}
+	i000 := l.Index(r0, g0, b0)
+	i001 := l.Index(r0, g0, b1)
+	i010 := l.Index(r0, g1, b0)
+	i011 := l.Index(r0, g1, b1)
+	i100 := l.Index(r1, g0, b0)
+	i101 := l.Index(r1, g0, b1)
+	i110 := l.Index(r1, g1, b0)
+	i111 := l.Index(r1, g1, b1)
 
-	iG := float64(g) * k
+	var out [3]uint16
 
-	var fG1 int
-	if iG >= float64(size)-1 {
-		fG1 = clampToChannelSize(size - 1)
-	} else {
-		fG1 = clampToChannelSize(int(math.Floor(iG + 1)))
-	}
+	for ch := range out {
+		c00 := fixed.Lerp(int32(l.Data[i000+ch]), int32(l.Data[i100+ch]), dr)
+		c01 := fixed.Lerp(int32(l.Data[i001+ch]), int32(l.Data[i101+ch]), dr)
+		c10 := fixed.Lerp(int32(l.Data[i010+ch]), int32(l.Data[i110+ch]), dr)
+		c11 := fixed.Lerp(int32(l.Data[i011+ch]), int32(l.Data[i111+ch]), dr)
 
-	var fG0 int
-	if iG > 0 {
-		fG0 = clampToChannelSize(int(math.Floor(iG - 1)))
-	}
-
-	iB := float64(b) * k
-
-	var fB1 int
-	if iB >= float64(size)-1 {
-		fB1 = clampToChannelSize(size - 1)
-	} else {
-		fB1 = clampToChannelSize(int(math.Floor(iB + 1)))
-	}
+		c0 := fixed.Lerp(c00, c10, dg)
+		c1 := fixed.Lerp(c01, c11, dg)
 
-	var fB0 int
-	if iB > 0 {
-		fB0 = clampToChannelSize(int(math.Floor(iB - 1)))
+		out[ch] = fixed.Clamp(int64(fixed.Lerp(c0, c1, db)))
 	}
 
-	c000 := cube.Get(fR0, fG0, fB0)
-	c010 := cube.Get(fR0, fG1, fB0)
-	c001 := cube.Get(fR0, fG0, fB1)
-	c011 := cube.Get(fR0, fG1, fB1)
-	c101 := cube.Get(fR1, fG0, fB1)
-	c100 := cube.Get(fR1, fG0, fB0)
-	c110 := cube.Get(fR1, fG1, fB0)
-	c111 := cube.Get(fR1, fG1, fB1)
-
-	rx := trilerp(
-		iR, iG, iB, c000[0], c001[0], c010[0], c011[0],
-		c100[0], c101[0], c110[0], c111[0],
-		float64(fR0), float64(fR1), float64(fG0), float64(fG1), float64(fB0), float64(fB1),
-	)
-
-	gx := trilerp(
-		iR, iG, iB, c000[1], c001[1], c010[1], c011[1],
-		c100[1], c101[1], c110[1], c111[1],
-		float64(fR0), float64(fR1), float64(fG0), float64(fG1), float64(fB0), float64(fB1),
-	)
-
-	bx := trilerp(
-		iR, iG, iB, c000[2], c001[2], c010[2], c011[2],
-		c100[2], c101[2], c110[2], c111[2],
-		float64(fR0), float64(fR1), float64(fG0), float64(fG1), float64(fB0), float64(fB1),
-	)
-
-	return []float64{rx, gx, bx}
+	return out[0], out[1], out[2]
 }
@@ -0,0 +1,130 @@
+package trilinear
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/wayneashleyberry/lut/pkg/colorcube"
+	"github.com/wayneashleyberry/lut/pkg/interptest"
+	"github.com/wayneashleyberry/lut/pkg/lut"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+		fn   lut.Func
+		max  float64
+	}{
+		{"identity", 2, interptest.Identity, 1},
+		{"identity", 33, interptest.Identity, 1},
+		{"gain", 17, interptest.Gain(0.8, 1, 0.5), 1},
+		{"matrix", 17, interptest.CrossTalk, 1},
+		{"gamma", 33, interptest.Gamma(2.2), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := interptest.Measure(Lookup, tt.size, tt.fn, 23)
+			if res.CodeValues() > tt.max {
+				t.Errorf("Lookup() max error = %v, mean error = %v", res.Max, res.Mean)
+			}
+		})
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 256, 64))
+
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 256; x++ {
+			src.SetNRGBA(x, y, color.NRGBA{uint8(x), uint8(255 - x), uint8(y * 4), 0xff})
+		}
+	}
+
+	out, err := Interpolate(src, interptest.Cube(17, interptest.Identity), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bounds := src.Bounds()
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if got, want := out.At(x, y), src.At(x, y); got != want {
+				t.Fatalf("Interpolate() at (%d, %d) = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestLookupDomain(t *testing.T) {
+	// An identity cube covering 0..2, as used for scene-linear images.
+	cube := colorcube.New(3, []float64{0, 0, 0}, []float64{2, 2, 2})
+
+	for x := 0; x < 3; x++ {
+		for y := 0; y < 3; y++ {
+			for z := 0; z < 3; z++ {
+				cube.Set(x, y, z, []float64{float64(x), float64(y), float64(z)})
+			}
+		}
+	}
+
+	tests := []struct {
+		in, want float64
+	}{
+		{0, 0},
+		{0.5, 0.5},
+		{1, 1},
+		{1.5, 1.5},
+		{2, 2},
+		{3, 2},
+		{-1, 0},
+	}
+
+	for _, tt := range tests {
+		r, g, b := Lookup(cube, tt.in, tt.in, tt.in)
+		if r != tt.want || g != tt.want || b != tt.want {
+			t.Errorf("Lookup(%v) = %v, %v, %v, want %v", tt.in, r, g, b, tt.want)
+		}
+	}
+}
+
+func TestFixed(t *testing.T) {
+	cube := interptest.Filter(t, "DU04.cube")
+
+	interptest.CheckFixed(t, cube, Lookup, NewFixed(cube))
+}
+
+func BenchmarkInterpolate(b *testing.B) {
+	benchmarkInterpolate(b)
+}
+
+func BenchmarkInterpolateFixed(b *testing.B) {
+	benchmarkInterpolate(b, lut.WithFixed(NewFixed(interptest.Cube(33, interptest.Gamma(2.2)))))
+}
+
+func benchmarkInterpolate(b *testing.B, opts ...lut.Option) {
+	src := image.NewNRGBA(image.Rect(0, 0, 512, 512))
+
+	for y := 0; y < 512; y++ {
+		for x := 0; x < 512; x++ {
+			src.SetNRGBA(x, y, color.NRGBA{uint8(x / 2), uint8(255 - y/2), uint8((x + y) / 4), 0xff})
+		}
+	}
+
+	cube := interptest.Cube(33, interptest.Gamma(2.2))
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := Interpolate(src, cube, 1, opts...); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	interptest.Check(t, Lookup, nil)
+}
@@ -5,12 +5,16 @@ import (
 	"errors"
 	"fmt"
 	"image"
+	"image/gif"
 	"image/jpeg"
 	"image/png"
 	"os"
 	"path"
 	"strconv"
 	"strings"
+
+	"github.com/wayneashleyberry/lut/pkg/floatimage"
+	"github.com/wayneashleyberry/lut/pkg/stream"
 )
 
 // Exit will shut down the process with a simple error message and the correct
@@ -36,6 +40,17 @@ func ReadImage(filename string) (image.Image, error) {
 		return jpeg.Decode(file)
 	case ".png":
 		return png.Decode(file)
+	case ".gif":
+		return gif.Decode(file)
+	case ".pfm":
+		return floatimage.DecodePFM(file)
+	case ".ppm", ".pgm":
+		dec, err := stream.NewPPMDecoder(file)
+		if err != nil {
+			return nil, err
+		}
+
+		return stream.Decode(dec)
 	default:
 		return nil, errors.New("unsupported output type: " + filename)
 	}
@@ -55,6 +70,7 @@ func WriteImage(filename string, img image.Image) error {
 		if err != nil {
 			return err
 		}
+		defer f.Close()
 
 		return jpeg.Encode(f, img, &jpeg.Options{
 			Quality: 100,
@@ -64,13 +80,58 @@ func WriteImage(filename string, img image.Image) error {
 		if err != nil {
 			return err
 		}
+		defer f.Close()
 
 		return png.Encode(f, img)
+	case ".gif":
+		f, err := os.Create(filename)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		return gif.Encode(f, img, nil)
+	case ".pfm":
+		f, err := os.Create(filename)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		return floatimage.EncodePFM(f, img)
+	case ".ppm":
+		f, err := os.Create(filename)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		enc, err := stream.NewPPMEncoder(f, stream.Header{
+			Width:  img.Bounds().Dx(),
+			Height: img.Bounds().Dy(),
+			Deep:   Deep(img),
+			Opaque: true,
+		})
+		if err != nil {
+			return err
+		}
+
+		return stream.Encode(enc, img)
 	default:
 		return errors.New("unsupported output type")
 	}
 }
 
+// Deep will report whether an image has more than 8 bits per channel.
+func Deep(img image.Image) bool {
+	switch img.(type) {
+	case *image.NRGBA64, *image.RGBA64, *image.Gray16, *floatimage.RGBAF32:
+		return true
+	default:
+		return false
+	}
+}
+
 // ParseFloats will parse space delimted floats from a string.
 func ParseFloats(in string, bitSize int) []float64 {
 	s := strings.TrimSpace(in)